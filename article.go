@@ -0,0 +1,143 @@
+package harvester
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	readability "github.com/julianshen/go-readability"
+	"golang.org/x/net/html"
+)
+
+// Article is the main content of an HTML page, extracted with a readability algorithm
+type Article struct {
+	HTML        string    // sanitized HTML of the main content
+	Text        string    // plain text of the main content, one paragraph per line
+	Byline      string    // the author(s), if the page declares them
+	PublishedOn time.Time // zero if the page doesn't declare when it was published
+	LeadImage   string    // absolute URL of the page's primary image, if any
+}
+
+// bylineMetaTags are checked, in order, for the article's author
+var bylineMetaTags = []string{"author", "article:author", "byl", "dc.creator", "DC.creator", "parsely-author", "sailthru.author"}
+
+// publishedMetaTags are checked, in order, for when the article was published
+var publishedMetaTags = []string{"article:published_time", "og:published_time", "datePublished", "date", "pubdate",
+	"publish-date", "dc.date.issued", "DC.date.issued", "sailthru.date", "parsely-pub-date"}
+
+// articleDateLayouts are the date formats tried when parsing publish dates
+var articleDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05",
+	"2006-01-02", time.RFC1123Z, time.RFC1123, "January 2, 2006", "Jan 2, 2006"}
+
+// extractArticle runs the readability algorithm on the page's HTML and combines the result with the
+// page's meta data
+func extractArticle(pageURL *url.URL, pageHTML string, content *HarvestedResourceContent) (*Article, error) {
+	doc, err := readability.NewDocument(pageHTML)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(Article)
+	result.HTML = strings.TrimSpace(doc.Content())
+	articleNode, _ := html.Parse(strings.NewReader(result.HTML))
+	result.Text = articleText(articleNode)
+	result.Byline = firstMetaValue(content, bylineMetaTags...)
+	for _, name := range publishedMetaTags {
+		if published, ok := parseArticleDate(content.metaPropertyTags[name]); ok {
+			result.PublishedOn = published
+			break
+		}
+	}
+	if result.PublishedOn.IsZero() {
+		if datetime, found := firstAttribute(articleNode, "time", "datetime"); found {
+			result.PublishedOn, _ = parseArticleDate(datetime)
+		}
+	}
+
+	leadImage := firstMetaValue(content, "og:image", "og:image:url", "twitter:image", "twitter:image:src")
+	if len(leadImage) == 0 {
+		leadImage, _ = firstAttribute(articleNode, "img", "src")
+	}
+	if len(leadImage) == 0 {
+		// the readability algorithm may have removed the images so fall back to the page's first image
+		pageNode, _ := html.Parse(strings.NewReader(pageHTML))
+		leadImage, _ = firstAttribute(pageNode, "img", "src")
+	}
+	if len(leadImage) > 0 {
+		if imageURL, err := pageURL.Parse(leadImage); err == nil {
+			leadImage = imageURL.String()
+		}
+		result.LeadImage = leadImage
+	}
+	return result, nil
+}
+
+// articleBlockElements start a new line in the plain text of an article
+var articleBlockElements = map[string]bool{"p": true, "div": true, "br": true, "li": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "tr": true, "figcaption": true}
+
+// articleText returns the text of the article, with whitespace collapsed and one block (e.g. paragraph) per line
+func articleText(n *html.Node) string {
+	var lines []string
+	var line strings.Builder
+	endLine := func() {
+		if words := strings.Fields(line.String()); len(words) > 0 {
+			lines = append(lines, strings.Join(words, " "))
+		}
+		line.Reset()
+	}
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		isBlock := n.Type == html.ElementNode && articleBlockElements[strings.ToLower(n.Data)]
+		if isBlock {
+			endLine()
+		}
+		if n.Type == html.TextNode {
+			line.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+		if isBlock {
+			endLine()
+		}
+	}
+	if n != nil {
+		f(n)
+	}
+	endLine()
+	return strings.Join(lines, "\n")
+}
+
+func parseArticleDate(text string) (time.Time, bool) {
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return time.Time{}, false
+	}
+	for _, layout := range articleDateLayouts {
+		if result, err := time.Parse(layout, text); err == nil {
+			return result, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// firstAttribute returns the value of the attribute of the first element with the given name
+func firstAttribute(n *html.Node, element string, attribute string) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+	if n.Type == html.ElementNode && strings.EqualFold(n.Data, element) {
+		for _, attr := range n.Attr {
+			if strings.EqualFold(attr.Key, attribute) && len(strings.TrimSpace(attr.Val)) > 0 {
+				return strings.TrimSpace(attr.Val), true
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if value, found := firstAttribute(c, element, attribute); found {
+			return value, true
+		}
+	}
+	return "", false
+}
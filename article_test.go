@@ -0,0 +1,93 @@
+package harvester
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// articleTestPage is a blog post surrounded by navigation and a footer
+const articleTestPage = `<html><head><title>Harvesting Links | Example Blog</title>
+		<meta name="author" content="Jane Writer" />
+		<meta property="article:published_time" content="2019-04-05T08:30:00Z" />
+		</head><body>
+		<div class="menu"><a href="/">Home</a> <a href="/about">About</a></div>
+		<div class="article-content">
+		<p>Harvesting links from content is the first step in building a curated library of resources, and it
+		turns out that following every redirect and cleaning every query parameter takes more care than expected.</p>
+		<img src="/images/lead.png" />
+		<p>Once the final destination is known, the page's meta data and main content can be stored alongside
+		the link so that readers can search the full text of everything that was ever shared with them.</p>
+		</div>
+		<div class="footer">Copyright Example Blog</div>
+		</body></html>`
+
+var articleTestFixtures = testFixtures{pages: map[string]string{
+	"/article": articleTestPage,
+	// twitter:creator is the author's handle rather than their name
+	"/tweeted-article": strings.Replace(articleTestPage, `name="author" content="Jane Writer"`, `name="twitter:creator" content="@janewriter"`, 1),
+}}
+
+type ArticleSuite struct {
+	harvesterSuite
+}
+
+func (suite *ArticleSuite) SetupSuite() {
+	suite.setupSuite(articleTestFixtures.handler())
+	suite.ch = MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{ExtractArticles: true})
+}
+
+func (suite *ArticleSuite) TestExtractArticle() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Read %s/article in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	article := harvested.Resources[0].ResourceContent().Article()
+	suite.Require().NotNil(article)
+
+	suite.Contains(article.Text, "Harvesting links from content is the first step")
+	suite.Contains(article.Text, "search the full text")
+	suite.NotContains(article.Text, "Copyright Example Blog", "Boilerplate should be removed")
+	suite.Equal(2, len(strings.Split(article.Text, "\n")), "Each paragraph should be on its own line")
+	suite.Contains(article.HTML, "<p>")
+	suite.Equal("Jane Writer", article.Byline)
+	suite.True(article.PublishedOn.Equal(time.Date(2019, 4, 5, 8, 30, 0, 0, time.UTC)))
+	suite.Equal(suite.server.URL+"/images/lead.png", article.LeadImage)
+
+	var out strings.Builder
+	err := harvested.Serialize(HarvestedResourcesSerializer{
+		GetKeys: func(hr *HarvestedResource) *HarvestedResourceKeys {
+			return CreateHarvestedResourceKeys(hr, func(random uint32, try int) bool { return false })
+		},
+		GetTemplate: func(keys *HarvestedResourceKeys) (*template.Template, error) {
+			return NewTemplate("article").Parse(`{{ .Article.Byline }}: {{ truncate 40 .Article.Text }}`)
+		},
+		GetTemplateParams: func(keys *HarvestedResourceKeys) *map[string]interface{} { return nil },
+		GetWriter:         func(keys *HarvestedResourceKeys) io.Writer { return &out },
+	})
+	suite.NoError(err)
+	suite.True(strings.HasPrefix(out.String(), "Jane Writer: Harvesting links"), out.String())
+}
+
+func (suite *ArticleSuite) TestTwitterHandleIsNotAByline() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Read %s/tweeted-article in a mock tweet", suite.server.URL), suite.span)
+	article := harvested.Resources[0].ResourceContent().Article()
+	suite.Require().NotNil(article)
+	suite.Empty(article.Byline)
+}
+
+func (suite *ArticleSuite) TestNotExtractedByDefault() {
+	ch := MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	defer ch.Close()
+	harvested := ch.HarvestResources(fmt.Sprintf("Read %s/article in a mock tweet", suite.server.URL), suite.span)
+	suite.Nil(harvested.Resources[0].ResourceContent().Article())
+	title, _ := harvested.Resources[0].ResourceContent().GetTitleElement()
+	suite.Equal("Harvesting Links | Example Blog", title)
+}
+
+func TestArticleSuite(t *testing.T) {
+	suite.Run(t, new(ArticleSuite))
+}
@@ -0,0 +1,140 @@
+package harvester
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BlobMetadata is written next to each blob (as <digest>.json) to describe where it came from
+type BlobMetadata struct {
+	Digest        string    `json:"digest"`
+	SourceURLs    []string  `json:"sourceURLs"`
+	ContentType   string    `json:"contentType,omitempty"`
+	FileExtension string    `json:"fileExtension,omitempty"`
+	Size          int64     `json:"size"`
+	FetchedOn     time.Time `json:"fetchedOn"`
+	LastFetchedOn time.Time `json:"lastFetchedOn"`
+}
+
+// BlobStore is a content-addressed store for downloaded content: each download is saved under its
+// SHA-256 digest so identical files downloaded from different resources are only stored once
+type BlobStore struct {
+	rootDir string
+}
+
+// MakeBlobStore prepares a blob store rooted at rootDir; the directory is created when first needed
+func MakeBlobStore(rootDir string) *BlobStore {
+	result := new(BlobStore)
+	result.rootDir = rootDir
+	return result
+}
+
+// RootDir returns the directory blobs are stored in
+func (s *BlobStore) RootDir() string {
+	return s.rootDir
+}
+
+// validateDigest makes sure digest is a hex-encoded SHA-256 digest, since it is used to build file paths
+func validateDigest(digest string) error {
+	if len(digest) != sha256.Size*2 {
+		return fmt.Errorf("blob digest %q should be %d hex characters", digest, sha256.Size*2)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return fmt.Errorf("blob digest %q is not hex-encoded: %v", digest, err)
+	}
+	return nil
+}
+
+// Path returns where the blob with the given digest and file extension is (or would be) stored; blobs
+// are spread across subdirectories named after the first two characters of the digest
+func (s *BlobStore) Path(digest string, extension string) (string, error) {
+	if err := validateDigest(digest); err != nil {
+		return "", err
+	}
+	name := digest
+	if len(extension) > 0 {
+		name += "." + extension
+	}
+	return filepath.Join(s.rootDir, digest[0:2], name), nil
+}
+
+// MetadataPath returns where the sidecar metadata file of the blob with the given digest is stored
+func (s *BlobStore) MetadataPath(digest string) (string, error) {
+	if err := validateDigest(digest); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.rootDir, digest[0:2], digest+".json"), nil
+}
+
+// Metadata reads the sidecar metadata of the blob with the given digest
+func (s *BlobStore) Metadata(digest string) (*BlobMetadata, error) {
+	path, err := s.MetadataPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := new(BlobMetadata)
+	return result, json.Unmarshal(data, result)
+}
+
+// Store moves the downloaded file into the blob store (or, if an identical blob already exists,
+// deletes it) and updates the sidecar metadata. The download's DestPath is changed to the blob's path
+// and the download is retained so that closing the harvester won't delete it.
+func (s *BlobStore) Store(dc *DownloadedContent, contentType string) error {
+	if len(dc.Digest) == 0 || len(dc.DestPath) == 0 {
+		return fmt.Errorf("download of %s has no digest or file to store", urlText(dc.URL))
+	}
+
+	blobPath, err := s.Path(dc.Digest, dc.FileType.Extension)
+	if err != nil {
+		return err
+	}
+	metadataPath, err := s.MetadataPath(dc.Digest)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(blobPath); err == nil {
+		if blobPath != dc.DestPath {
+			os.Remove(dc.DestPath)
+		}
+		dc.DestPath = blobPath
+		dc.Retain()
+	} else if err := dc.MoveTo(blobPath); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	metadata, err := s.Metadata(dc.Digest)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		metadata = &BlobMetadata{Digest: dc.Digest, FetchedOn: now}
+	}
+	if dc.URL != nil && !containsString(metadata.SourceURLs, dc.URL.String()) {
+		metadata.SourceURLs = append(metadata.SourceURLs, dc.URL.String())
+	}
+	if len(dc.FileType.MIME.Value) > 0 {
+		metadata.ContentType = dc.FileType.MIME.Value
+	} else if len(metadata.ContentType) == 0 {
+		metadata.ContentType = contentType
+	}
+	metadata.FileExtension = dc.FileType.Extension
+	metadata.Size = dc.Size
+	metadata.LastFetchedOn = now
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(metadataPath, data)
+}
@@ -0,0 +1,154 @@
+package harvester
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DefaultMaxHTMLBytes is how much (decompressed) HTML is inspected when no limit is configured
+const DefaultMaxHTMLBytes int64 = 10 << 20
+
+// acceptedContentEncodings is sent with every harvest request; decodeContentEncoding handles each of them
+const acceptedContentEncodings = "gzip, deflate, br"
+
+// newHarvestRequest prepares a GET request which accepts the compressed encodings the harvester can decode
+func newHarvestRequest(urlText string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, urlText, nil)
+	if err != nil {
+		return nil, err
+	}
+	// setting the header turns off the HTTP client's transparent gzip support so decoding is always ours
+	req.Header.Set("Accept-Encoding", acceptedContentEncodings)
+	return req, nil
+}
+
+// decodeContentEncoding replaces the body of resp with one that undoes its Content-Encoding and returns
+// the encoding that was removed; an unsupported encoding leaves the body as is and returns an error
+func decodeContentEncoding(resp *http.Response) (string, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if resp.Uncompressed {
+		// the HTTP client already removed the encoding
+		return "gzip", nil
+	}
+
+	var decoded io.Reader
+	switch encoding {
+	case "", "identity":
+		return "", nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return encoding, err
+		}
+		decoded = reader
+	case "deflate":
+		// deflate is supposed to be zlib wrapped but some servers send raw deflate data
+		body := bufio.NewReader(resp.Body)
+		header, _ := body.Peek(2)
+		if len(header) == 2 && header[0]&0x0F == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			reader, err := zlib.NewReader(body)
+			if err != nil {
+				return encoding, err
+			}
+			decoded = reader
+		} else {
+			decoded = flate.NewReader(body)
+		}
+	case "br":
+		decoded = brotli.NewReader(resp.Body)
+	default:
+		return encoding, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{decoded, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return encoding, nil
+}
+
+// htmlSizeLimiter stops reading once limit bytes were read and remembers whether there was more
+type htmlSizeLimiter struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *htmlSizeLimiter) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		var probe [1]byte
+		if n, _ := io.ReadFull(l.reader, probe[:]); n > 0 {
+			l.exceeded = true
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// headEndTag is where reading stops when only the <head> of a page is inspected
+var headEndTag = []byte("</head>")
+
+// headOnlyReader returns the content up to and including </head> (case-insensitive), then io.EOF
+type headOnlyReader struct {
+	reader  io.Reader
+	matched int // how much of headEndTag the content read so far ends with
+	done    bool
+}
+
+func (h *headOnlyReader) Read(p []byte) (int, error) {
+	if h.done {
+		return 0, io.EOF
+	}
+	n, err := h.reader.Read(p)
+	for i := 0; i < n; i++ {
+		switch {
+		case bytes.EqualFold(p[i:i+1], headEndTag[h.matched:h.matched+1]):
+			h.matched++
+		case bytes.EqualFold(p[i:i+1], headEndTag[:1]):
+			h.matched = 1
+		default:
+			h.matched = 0
+		}
+		if h.matched == len(headEndTag) {
+			h.done = true
+			return i + 1, nil
+		}
+	}
+	return n, err
+}
+
+// limitHTMLBody caps how much of the HTML body of resp is read, optionally stopping after </head>; the
+// returned limiter reports whether the limit cut the content short
+func limitHTMLBody(resp *http.Response, maxBytes int64, headOnly bool) *htmlSizeLimiter {
+	var reader io.Reader = resp.Body
+	var limiter *htmlSizeLimiter
+	if maxBytes > 0 {
+		limiter = &htmlSizeLimiter{reader: reader, remaining: maxBytes}
+		reader = limiter
+	}
+	if headOnly {
+		reader = &headOnlyReader{reader: reader}
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+	return limiter
+}
@@ -0,0 +1,159 @@
+package harvester
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/suite"
+)
+
+const compressedPage = `<html><head><title>Compressed Page</title>
+	<meta property="og:title" content="Compressed" /></head><body><p>Body text</p></body></html>`
+
+type BodySuite struct {
+	harvesterSuite
+	acceptEncoding string
+}
+
+func compress(encoding string, text string) []byte {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	}
+	io.WriteString(writer, text)
+	writer.Close()
+	return buf.Bytes()
+}
+
+func (suite *BodySuite) SetupSuite() {
+	suite.setupSuite(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/gzip", "/deflate", "/br":
+			w.Header().Set("Content-Encoding", strings.TrimPrefix(r.URL.Path, "/"))
+			w.Write(compress(strings.TrimPrefix(r.URL.Path, "/"), compressedPage))
+		case "/raw-deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Write(compress("raw-deflate", compressedPage))
+		case "/unsupported":
+			w.Header().Set("Content-Encoding", "compress")
+			fmt.Fprint(w, compressedPage)
+		case "/large":
+			// a page which is streamed in chunks with its <body> beyond the inspection limit
+			fmt.Fprint(w, `<html><head><title>Large Page</title></head><body>`)
+			w.(http.Flusher).Flush()
+			for i := 0; i < 1000; i++ {
+				fmt.Fprintf(w, "<p>Paragraph %d of a very long page.</p>", i)
+			}
+			fmt.Fprint(w, `<script type="application/ld+json">{"@type": "Article", "headline": "Too late"}</script></body></html>`)
+		default:
+			fmt.Fprint(w, compressedPage)
+		}
+	}))
+}
+
+func (suite *BodySuite) harvest(path string, options ContentHarvesterOptions) *HarvestedResource {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, options)
+	defer ch.Close()
+	harvested := ch.HarvestResources(fmt.Sprintf("Test page %s%s in a mock tweet", suite.server.URL, path), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return harvested.Resources[0]
+}
+
+func (suite *BodySuite) TestContentEncodings() {
+	for _, path := range []string{"/gzip", "/deflate", "/raw-deflate", "/br", "/identity"} {
+		hr := suite.harvest(path, ContentHarvesterOptions{})
+		suite.Equal("gzip, deflate, br", suite.acceptEncoding)
+		suite.Equal(ResourceResolved, hr.Status(), path)
+		content := hr.ResourceContent()
+		title, _ := content.GetTitleElement()
+		suite.Equal("Compressed Page", title, path)
+		suite.Equal("Body text", content.bodyTextSample, path)
+		isTruncated, _ := content.IsTruncated()
+		suite.False(isTruncated, path)
+	}
+	suite.Equal("br", suite.harvest("/br", ContentHarvesterOptions{}).ResourceContent().ContentEncoding())
+	suite.Equal("deflate", suite.harvest("/raw-deflate", ContentHarvesterOptions{}).ResourceContent().ContentEncoding())
+	suite.Equal("", suite.harvest("/identity", ContentHarvesterOptions{}).ResourceContent().ContentEncoding())
+}
+
+func (suite *BodySuite) TestUnsupportedContentEncoding() {
+	hr := suite.harvest("/unsupported", ContentHarvesterOptions{})
+	suite.Equal(ResourceContentError, hr.Status())
+	var parseErr *ParseError
+	suite.True(errors.As(hr.Err(), &parseErr))
+	suite.Equal("content encoding", parseErr.Subject)
+}
+
+func (suite *BodySuite) TestSizeLimit() {
+	hr := suite.harvest("/large", ContentHarvesterOptions{MaxHTMLBytes: 4096})
+	content := hr.ResourceContent()
+	title, _ := content.GetTitleElement()
+	suite.Equal("Large Page", title)
+	isTruncated, err := content.IsTruncated()
+	suite.True(isTruncated)
+	var tooLarge *TooLargeError
+	suite.Require().True(errors.As(err, &tooLarge))
+	suite.Equal(int64(4096), tooLarge.Limit)
+	suite.Nil(content.StructuredData().Article(), "Content beyond the limit should not be parsed")
+	suite.Equal(ResourceResolved, hr.Status(), "Truncated content is still harvested")
+
+	hr = suite.harvest("/large", ContentHarvesterOptions{MaxHTMLBytes: -1})
+	isTruncated, _ = hr.ResourceContent().IsTruncated()
+	suite.False(isTruncated)
+	suite.NotNil(hr.ResourceContent().StructuredData().Article())
+}
+
+func (suite *BodySuite) TestHeadOnly() {
+	content := suite.harvest("/gzip", ContentHarvesterOptions{InspectHeadOnly: true}).ResourceContent()
+	value, _ := content.GetOpenGraphMetaTag("title")
+	suite.Equal("Compressed", value)
+	suite.Equal("", content.bodyTextSample, "The <body> should not have been read")
+	isTruncated, _ := content.IsTruncated()
+	suite.False(isTruncated, "Stopping after </head> was requested so it isn't a truncation")
+
+	content = suite.harvest("/gzip", ContentHarvesterOptions{InspectHeadOnly: true, ExtractArticles: true}).ResourceContent()
+	suite.Equal("Body text", content.bodyTextSample, "Articles need the <body>")
+}
+
+func (suite *BodySuite) TestHeadOnlyReaderAcrossReads() {
+	reader := &headOnlyReader{reader: &oneByteReader{strings.NewReader("<head><title>x</title></HEAD><body>y</body>")}}
+	var out bytes.Buffer
+	_, err := io.Copy(&out, reader)
+	suite.NoError(err)
+	suite.Equal("<head><title>x</title></HEAD>", out.String())
+}
+
+// oneByteReader returns one byte per Read to exercise matches which span reads
+type oneByteReader struct {
+	reader io.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.reader.Read(p[:1])
+}
+
+func TestBodySuite(t *testing.T) {
+	suite.Run(t, new(BodySuite))
+}
@@ -0,0 +1,144 @@
+package harvester
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// CharsetSource is how the character encoding of HTML content was determined
+type CharsetSource string
+
+// The ways a character encoding is determined, in order of precedence
+const (
+	CharsetFromBOM         CharsetSource = "bom"          // a byte order mark at the start of the content
+	CharsetFromContentType CharsetSource = "content-type" // the charset parameter of the Content-Type header
+	CharsetFromMetaTag     CharsetSource = "meta"         // <meta charset> or <meta http-equiv="Content-Type">
+	CharsetSniffed         CharsetSource = "sniffed"      // UTF-8 if the bytes are valid UTF-8, otherwise windows-1252
+)
+
+// charsetSniffLength is how much of the content is examined for <meta charset> and sniffing; it's more
+// than the 1024 bytes browsers use because many pages have long scripts or comments before the tag
+const charsetSniffLength = 16 * 1024
+
+// byteOrderMarks are checked before anything else, as browsers do
+var byteOrderMarks = []struct {
+	bom     []byte
+	charset string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+}
+
+// decodeHTMLBody determines the character encoding of the HTML in resp and replaces its body with one that
+// is transcoded to UTF-8; the encoding and how it was found are recorded on the content
+func (c *HarvestedResourceContent) decodeHTMLBody(resp *http.Response) {
+	body := bufio.NewReaderSize(resp.Body, charsetSniffLength)
+	// a short or failed read still leaves whatever could be peeked, which is all there is to go on
+	peek, _ := body.Peek(charsetSniffLength)
+
+	var enc encoding.Encoding
+	enc, c.charset, c.charsetSource = determineCharset(peek, c.mediaTypeParams["charset"])
+	if _, length := byteOrderMark(peek); length > 0 {
+		// the parser would otherwise treat the mark as text and start the <body> before any <head> tags
+		body.Discard(length)
+	}
+	var reader io.Reader = body
+	if enc != encoding.Nop {
+		reader = transform.NewReader(body, enc.NewDecoder())
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+}
+
+// determineCharset returns the encoding of content, its canonical name and how it was determined
+func determineCharset(content []byte, contentTypeCharset string) (encoding.Encoding, string, CharsetSource) {
+	if label, _ := byteOrderMark(content); len(label) > 0 {
+		enc, name := charset.Lookup(label)
+		return enc, name, CharsetFromBOM
+	}
+
+	if enc, name := charset.Lookup(contentTypeCharset); enc != nil {
+		return enc, name, CharsetFromContentType
+	}
+
+	if enc, name := charset.Lookup(metaCharset(content)); enc != nil {
+		// a document can't declare itself UTF-16 in a tag it must be able to read as ASCII
+		if strings.HasPrefix(name, "utf-16") {
+			enc, name = charset.Lookup("utf-8")
+		}
+		return enc, name, CharsetFromMetaTag
+	}
+
+	// ignore a rune that was cut off by the end of the sample
+	for i := len(content) - 1; i >= 0 && i > len(content)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(content[i]) {
+			if !utf8.FullRune(content[i:]) {
+				content = content[:i]
+			}
+			break
+		}
+	}
+	if utf8.Valid(content) {
+		enc, name := charset.Lookup("utf-8")
+		return enc, name, CharsetSniffed
+	}
+	enc, name := charset.Lookup("windows-1252")
+	return enc, name, CharsetSniffed
+}
+
+// byteOrderMark returns the charset and length of the byte order mark content starts with, if any
+func byteOrderMark(content []byte) (string, int) {
+	for _, mark := range byteOrderMarks {
+		if bytes.HasPrefix(content, mark.bom) {
+			return mark.charset, len(mark.bom)
+		}
+	}
+	return "", 0
+}
+
+// metaCharset returns the charset declared by <meta charset> or <meta http-equiv="Content-Type"> in content
+func metaCharset(content []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			if !strings.EqualFold(token.Data, "meta") {
+				if strings.EqualFold(token.Data, "body") {
+					return ""
+				}
+				continue
+			}
+			var httpEquiv, contentValue string
+			for _, attr := range token.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "charset":
+					return strings.TrimSpace(attr.Val)
+				case "http-equiv":
+					httpEquiv = attr.Val
+				case "content":
+					contentValue = attr.Val
+				}
+			}
+			if strings.EqualFold(strings.TrimSpace(httpEquiv), "content-type") {
+				if index := strings.Index(strings.ToLower(contentValue), "charset="); index >= 0 {
+					return strings.Trim(strings.TrimSpace(contentValue[index+len("charset="):]), `"';`)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package harvester
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// charsetPage is a test page encoded in a legacy character set
+type charsetPage struct {
+	contentType string
+	encoding    encoding.Encoding
+	html        string
+}
+
+var charsetPages = map[string]charsetPage{
+	"/shift-jis-header": {"text/html; charset=Shift_JIS", japanese.ShiftJIS,
+		`<html><head><title>こんにちは世界</title></head><body></body></html>`},
+	"/windows-1251-meta": {"text/html", charmap.Windows1251,
+		`<html><head><meta charset="windows-1251"><title>Привет мир</title></head><body></body></html>`},
+	"/latin1-http-equiv": {"text/html", charmap.ISO8859_1,
+		`<html><head><meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">
+		<title>Café Crème</title><meta property="og:title" content="Déjà vu" /></head><body></body></html>`},
+	"/utf8-bom": {"text/html; charset=windows-1252", nil,
+		"\xEF\xBB\xBF<html><head><title>Überschrift</title></head><body></body></html>"},
+	"/utf8-sniffed": {"text/html", nil,
+		`<html><head><title>Ceci n'est pas une pipe — ½</title></head><body></body></html>`},
+	"/latin1-sniffed": {"text/html", charmap.Windows1252,
+		`<html><head><title>Naïve façade</title></head><body></body></html>`},
+}
+
+type CharsetSuite struct {
+	harvesterSuite
+}
+
+func (suite *CharsetSuite) SetupSuite() {
+	suite.setupSuite(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, found := charsetPages[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		body := page.html
+		if page.encoding != nil {
+			encoded, err := page.encoding.NewEncoder().String(body)
+			suite.Require().NoError(err)
+			body = encoded
+		}
+		w.Header().Set("Content-Type", page.contentType)
+		fmt.Fprint(w, body)
+	}))
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+}
+
+func (suite *CharsetSuite) content(path string) *HarvestedResourceContent {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s%s in a mock tweet", suite.server.URL, path), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return harvested.Resources[0].ResourceContent()
+}
+
+func (suite *CharsetSuite) assertTitle(path, title, charset string, source CharsetSource) {
+	content := suite.content(path)
+	actualTitle, _ := content.GetTitleElement()
+	suite.Equal(title, actualTitle, path)
+	actualCharset, actualSource := content.Charset()
+	suite.Equal(charset, actualCharset, path)
+	suite.Equal(source, actualSource, path)
+}
+
+func (suite *CharsetSuite) TestContentTypeHeader() {
+	suite.assertTitle("/shift-jis-header", "こんにちは世界", "shift_jis", CharsetFromContentType)
+}
+
+func (suite *CharsetSuite) TestMetaTags() {
+	suite.assertTitle("/windows-1251-meta", "Привет мир", "windows-1251", CharsetFromMetaTag)
+	suite.assertTitle("/latin1-http-equiv", "Café Crème", "windows-1252", CharsetFromMetaTag)
+	value, _ := suite.content("/latin1-http-equiv").GetOpenGraphMetaTag("title")
+	suite.Equal("Déjà vu", value)
+}
+
+func (suite *CharsetSuite) TestByteOrderMarkOverridesHeader() {
+	suite.assertTitle("/utf8-bom", "Überschrift", "utf-8", CharsetFromBOM)
+}
+
+func (suite *CharsetSuite) TestSniffing() {
+	suite.assertTitle("/utf8-sniffed", "Ceci n'est pas une pipe — ½", "utf-8", CharsetSniffed)
+	suite.assertTitle("/latin1-sniffed", "Naïve façade", "windows-1252", CharsetSniffed)
+}
+
+func (suite *CharsetSuite) TestSlugIsNotMojibake() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s/windows-1251-meta in a mock tweet", suite.server.URL), suite.span)
+	keys := CreateHarvestedResourceKeys(harvested.Resources[0], func(random uint32, try int) bool { return false })
+	suite.Equal("Привет мир", keys.Title())
+	suite.Equal("privet-mir", keys.Slug())
+}
+
+func (suite *CharsetSuite) TestRetainedHTMLMatchesItsCharset() {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{RetainHTML: true})
+	defer ch.Close()
+	harvested := ch.HarvestResources(fmt.Sprintf("Test page %s/latin1-http-equiv in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+
+	html, err := harvested.Resources[0].ResourceContent().HTML()
+	suite.Require().NoError(err)
+	defer html.Close()
+	retained, err := ioutil.ReadAll(html)
+	suite.Require().NoError(err)
+	page := charsetPages["/latin1-http-equiv"]
+	served, _ := page.encoding.NewEncoder().String(page.html)
+	suite.Equal(served, string(retained), "The HTML should be retained in the encoding its <meta> declares")
+}
+
+func (suite *CharsetSuite) TestDetermineCharsetWithTruncatedRune() {
+	// the sample ends in the middle of a multi-byte rune, which shouldn't make it invalid UTF-8
+	_, name, source := determineCharset([]byte("<title>Привет")[:12], "")
+	suite.Equal("utf-8", name)
+	suite.Equal(CharsetSniffed, source)
+}
+
+func TestCharsetSuite(t *testing.T) {
+	suite.Run(t, new(CharsetSuite))
+}
@@ -0,0 +1,192 @@
+package harvester
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVColumn is a single column of a CSV link report
+type CSVColumn struct {
+	Name  string
+	Value func(hr *HarvestedResource, keys *HarvestedResourceKeys) string
+}
+
+// The columns available to CSV link reports
+var (
+	CSVOriginalURLColumn = CSVColumn{"originalURL", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return hr.OriginalURLText()
+	}}
+	CSVFinalURLColumn = CSVColumn{"finalURL", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return urlText(hr.finalURL)
+	}}
+	CSVResolvedURLColumn = CSVColumn{"resolvedURL", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return urlText(hr.resolvedURL)
+	}}
+	CSVStatusCodeColumn = CSVColumn{"statusCode", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		if hr.httpStatusCode == 0 {
+			return ""
+		}
+		return strconv.Itoa(hr.httpStatusCode)
+	}}
+	CSVStatusColumn = CSVColumn{"status", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return hr.Status().String()
+	}}
+	CSVGoneColumn = CSVColumn{"gone", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return strconv.FormatBool(hr.isGone)
+	}}
+	CSVValidColumn = CSVColumn{"valid", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		isURLValid, isDestValid := hr.IsValid()
+		return strconv.FormatBool(isURLValid && isDestValid)
+	}}
+	CSVIgnoredColumn = CSVColumn{"ignored", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return strconv.FormatBool(hr.isURLIgnored)
+	}}
+	CSVIgnoreReasonColumn = CSVColumn{"ignoreReason", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return hr.ignoreReason
+	}}
+	CSVErrorColumn = CSVColumn{"error", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return errorText(hr.Err())
+	}}
+	CSVCleanedColumn = CSVColumn{"cleaned", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return strconv.FormatBool(hr.isURLCleaned)
+	}}
+	CSVContentTypeColumn = CSVColumn{"contentType", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		content := hr.ResourceContent()
+		if content == nil {
+			return ""
+		}
+		if content.downloaded != nil && len(content.downloaded.FileType.MIME.Value) > 0 {
+			return content.downloaded.FileType.MIME.Value
+		}
+		return content.mediaType
+	}}
+	CSVTitleColumn = CSVColumn{"title", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return keys.Title()
+	}}
+	CSVSiteNameColumn = CSVColumn{"siteName", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return resourceMetaTag(hr, "og:site_name")
+	}}
+	CSVDescriptionColumn = CSVColumn{"description", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return keys.Description()
+	}}
+	CSVSlugColumn = CSVColumn{"slug", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return keys.Slug()
+	}}
+	CSVHarvestedOnColumn = CSVColumn{"harvestedOn", func(hr *HarvestedResource, keys *HarvestedResourceKeys) string {
+		return hr.harvestedOn.Format(time.RFC3339)
+	}}
+)
+
+// AvailableCSVColumns returns every column which may be included in a CSV link report, in default order
+func AvailableCSVColumns() []CSVColumn {
+	return []CSVColumn{CSVOriginalURLColumn, CSVFinalURLColumn, CSVResolvedURLColumn, CSVStatusColumn,
+		CSVStatusCodeColumn, CSVValidColumn, CSVGoneColumn, CSVIgnoredColumn, CSVIgnoreReasonColumn, CSVErrorColumn, CSVCleanedColumn,
+		CSVContentTypeColumn, CSVTitleColumn, CSVSiteNameColumn, CSVDescriptionColumn, CSVSlugColumn, CSVHarvestedOnColumn}
+}
+
+// DefaultCSVColumns returns the columns included in a CSV link report unless others are requested
+func DefaultCSVColumns() []CSVColumn {
+	return []CSVColumn{CSVOriginalURLColumn, CSVFinalURLColumn, CSVStatusColumn, CSVStatusCodeColumn,
+		CSVIgnoredColumn, CSVIgnoreReasonColumn, CSVCleanedColumn, CSVContentTypeColumn, CSVTitleColumn, CSVSiteNameColumn,
+		CSVDescriptionColumn, CSVHarvestedOnColumn}
+}
+
+// CSVColumnsByName returns the available columns with the given names, in the given order
+func CSVColumnsByName(names ...string) ([]CSVColumn, error) {
+	available := make(map[string]CSVColumn)
+	for _, column := range AvailableCSVColumns() {
+		available[column.Name] = column
+	}
+
+	result := make([]CSVColumn, 0, len(names))
+	for _, name := range names {
+		column, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		result = append(result, column)
+	}
+	return result, nil
+}
+
+// CSVExporter flattens harvested resources into a CSV link report, one row per resource
+type CSVExporter struct {
+	columns        []CSVColumn
+	includeInvalid bool
+	includeIgnored bool
+	keysOptions    HarvestedResourceKeysOptions
+}
+
+// MakeCSVExporter prepares a CSV exporter with the given columns. Unlike Serialize, the exporter can
+// include invalid resources (unparseable URLs or invalid destinations) and ignored resources. Values
+// starting with =, +, -, @, tab or carriage return are prefixed with an apostrophe so that spreadsheets
+// don't evaluate them as formulas.
+func MakeCSVExporter(columns []CSVColumn, includeInvalid bool, includeIgnored bool, keysOptions HarvestedResourceKeysOptions) *CSVExporter {
+	result := new(CSVExporter)
+	result.columns = columns
+	result.includeInvalid = includeInvalid
+	result.includeIgnored = includeIgnored
+	result.keysOptions = keysOptions
+	return result
+}
+
+// MakeDefaultCSVExporter prepares a CSV exporter with the default columns which includes every resource
+func MakeDefaultCSVExporter() *CSVExporter {
+	return MakeCSVExporter(DefaultCSVColumns(), true, true, HarvestedResourceKeysOptions{})
+}
+
+// Export writes a header row and then one row per included resource
+func (e *CSVExporter) Export(w io.Writer, r *HarvestedResources) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, hr := range r.Resources {
+		if !e.includes(hr) {
+			continue
+		}
+		keys := CreateHarvestedResourceKeysWithOptions(hr, func(random uint32, try int) bool { return false }, e.keysOptions)
+		row := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			row[i] = neutralizeCSVFormula(column.Value(hr, keys))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvFormulaPrefixes are the characters which make spreadsheets treat a cell as a formula
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// neutralizeCSVFormula prefixes values which a spreadsheet would evaluate as a formula with an apostrophe
+// so that harvested titles, descriptions and URLs are always shown as text
+func neutralizeCSVFormula(value string) string {
+	if len(value) > 0 && strings.IndexByte(csvFormulaPrefixes, value[0]) >= 0 {
+		return "'" + value
+	}
+	return value
+}
+
+func (e *CSVExporter) includes(hr *HarvestedResource) bool {
+	isURLValid, isDestValid := hr.IsValid()
+	if !isURLValid || !isDestValid {
+		return e.includeInvalid
+	}
+	if hr.isURLIgnored {
+		return e.includeIgnored
+	}
+	return true
+}
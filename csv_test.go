@@ -0,0 +1,77 @@
+package harvester
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CSVSuite struct {
+	harvesterSuite
+	harvested *HarvestedResources
+}
+
+func (suite *CSVSuite) SetupSuite() {
+	suite.setupSuite(testFixtures{}.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	suite.harvested = suite.ch.HarvestResources(fmt.Sprintf("Pages %[1]s/og %[1]s/missing %[1]s/title-only %[1]s/untitled in a mock tweet", suite.server.URL), suite.span)
+}
+
+func (suite *CSVSuite) TestCSVExport() {
+	var out strings.Builder
+	suite.NoError(MakeDefaultCSVExporter().Export(&out, suite.harvested))
+	rows, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	suite.NoError(err)
+	suite.Equal(5, len(rows), "All resources, including invalid ones, should be exported")
+	suite.Equal([]string{"originalURL", "finalURL", "status", "statusCode", "ignored", "ignoreReason", "cleaned", "contentType", "title", "siteName", "description", "harvestedOn"}, rows[0])
+	suite.Equal("Open Graph Title", rows[1][8])
+	suite.Equal("text/html", rows[1][7])
+	suite.Equal("resolved", rows[1][2])
+	suite.Equal("http-error", rows[2][2])
+	suite.Equal("404", rows[2][3])
+	suite.Equal("false", rows[2][4])
+
+	columns, err := CSVColumnsByName("finalURL", "title")
+	suite.NoError(err)
+	out.Reset()
+	suite.NoError(MakeCSVExporter(columns, false, false, HarvestedResourceKeysOptions{}).Export(&out, suite.harvested))
+	rows, _ = csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	suite.Equal(4, len(rows), "Invalid resources should be excluded")
+	suite.Equal([]string{suite.server.URL + "/og", "Open Graph Title"}, rows[1])
+
+	_, err = CSVColumnsByName("nope")
+	suite.Error(err)
+}
+
+func (suite *CSVSuite) TestCSVExportNeutralizesFormulas() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>=HYPERLINK("https://evil.example/","Click")</title>
+<meta name="description" content="@SUM(1+1)"></head><body></body></html>`)
+	}))
+	defer server.Close()
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Page %s/formula in a mock tweet", server.URL), suite.span)
+
+	columns, err := CSVColumnsByName("finalURL", "title", "description")
+	suite.Require().NoError(err)
+	var out strings.Builder
+	suite.NoError(MakeCSVExporter(columns, true, true, HarvestedResourceKeysOptions{}).Export(&out, harvested))
+	rows, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, len(rows))
+	suite.Equal([]string{server.URL + "/formula", `'=HYPERLINK("https://evil.example/","Click")`, "'@SUM(1+1)"}, rows[1])
+
+	for _, value := range []string{"+1", "-1", "\tx", "\rx"} {
+		suite.Equal("'"+value, neutralizeCSVFormula(value))
+	}
+	suite.Equal("Plain title", neutralizeCSVFormula("Plain title"))
+}
+
+func TestCSVSuite(t *testing.T) {
+	suite.Run(t, new(CSVSuite))
+}
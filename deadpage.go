@@ -0,0 +1,286 @@
+package harvester
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lectio/observe"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// DeadPageKind is what kind of dead page a DeadPageDetector found
+type DeadPageKind int
+
+const (
+	// LivePage means no dead page signals were found (or the confidence was below the threshold)
+	LivePage DeadPageKind = iota
+
+	// Soft404Page is a "page not found" page served with an accepted status code
+	Soft404Page
+
+	// ParkedDomainPage is a domain parking (or domain for sale) landing page
+	ParkedDomainPage
+)
+
+func (k DeadPageKind) String() string {
+	switch k {
+	case LivePage:
+		return "live"
+	case Soft404Page:
+		return "soft-404"
+	case ParkedDomainPage:
+		return "parked-domain"
+	}
+	return fmt.Sprintf("DeadPageKind(%d)", int(k))
+}
+
+// DeadPageDetection is the result of running a DeadPageDetector on content. Confidence is between 0
+// and 1; Kind is LivePage unless the confidence reached the detector's threshold.
+type DeadPageDetection struct {
+	Kind       DeadPageKind
+	Confidence float64
+	Signals    []string
+}
+
+// IsDead returns true if the content was flagged as a soft 404 or parked domain
+func (d *DeadPageDetection) IsDead() bool {
+	return d != nil && d.Kind != LivePage
+}
+
+// DefaultParkingProviderHosts are hosts of domain parking and domain sale providers; pages which are
+// served from, redirect to or embed scripts and frames from these hosts are likely parked
+var DefaultParkingProviderHosts = []string{
+	"sedoparking.com", "sedo.com", "parkingcrew.net", "bodis.com", "above.com", "parklogic.com",
+	"dan.com", "afternic.com", "hugedomains.com", "undeveloped.com", "domainmarket.com",
+	"cashparking.com", "parked.com", "voodoo.com", "skenzo.com", "fabulous.com",
+}
+
+// DefaultParkedDomainPatterns match the visible text of typical domain parking landing pages
+var DefaultParkedDomainPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(this|the)\s+domain(\s+name)?\s+(\S+\s+)?(is|may\s+be)\s+for\s+sale\b`),
+	regexp.MustCompile(`(?i)\bbuy\s+this\s+domain\b`),
+	regexp.MustCompile(`(?i)\bthis\s+(web\s+page|webpage|page)\s+(is\s+)?parked\b`),
+	regexp.MustCompile(`(?i)\bdomain\s+parking\b`),
+}
+
+// The weight of each signal; a detection's confidence combines the weights of all signals found
+// (as the probability that at least one of them is right)
+const (
+	soft404TitleSignalWeight   = 0.7
+	soft404BodySignalWeight    = 0.6
+	parkingHostSignalWeight    = 0.9
+	parkingTextSignalWeight    = 0.6
+	probeMatchSignalWeight     = 0.5
+	probeAcceptedSignalWeight  = 0.3
+	probeNotFoundDiscountRatio = 0.5
+)
+
+// DefaultDeadPageProbeTimeout is how long the random path probe of a host may take
+const DefaultDeadPageProbeTimeout = 10 * time.Second
+
+// DeadPageDetector flags soft 404s and parked domains using title and body heuristics, known parking
+// provider signatures and, optionally, a comparison against the response to a random path on the same
+// host (which, on hosts that serve soft 404s, returns the same page)
+type DeadPageDetector struct {
+	threshold            float64
+	probeRandomPath      bool
+	statusPolicy         *HTTPStatusPolicy // where the soft 404 patterns come from, nil for the defaults
+	parkedPatterns       []*regexp.Regexp
+	parkingProviderHosts []string
+	probeClient          *http.Client
+
+	probesMutex sync.Mutex
+	probes      map[string]*deadPageProbe // keyed by scheme://host, nil if the probe failed
+}
+
+// deadPageProbe is the response to a random path on a host
+type deadPageProbe struct {
+	statusCode int
+	content    *HarvestedResourceContent
+}
+
+// MakeDeadPageDetector prepares a detector which flags content once the confidence reaches threshold. Soft
+// 404s are recognized by statusPolicy's Soft404TitlePatterns and Soft404BodyPatterns; when statusPolicy is
+// nil, the harvester's status policy is used (and Detect uses the default patterns).
+func MakeDeadPageDetector(threshold float64, probeRandomPath bool, statusPolicy *HTTPStatusPolicy) *DeadPageDetector {
+	result := new(DeadPageDetector)
+	result.threshold = threshold
+	result.probeRandomPath = probeRandomPath
+	result.statusPolicy = statusPolicy
+	result.parkedPatterns = DefaultParkedDomainPatterns
+	result.parkingProviderHosts = DefaultParkingProviderHosts
+	result.probeClient = &http.Client{Timeout: DefaultDeadPageProbeTimeout}
+	result.probes = make(map[string]*deadPageProbe)
+	return result
+}
+
+// MakeDefaultDeadPageDetector prepares a detector with a threshold of 0.75 which probes random paths
+func MakeDefaultDeadPageDetector() *DeadPageDetector {
+	return MakeDeadPageDetector(0.75, true, nil)
+}
+
+// Detect inspects HTML content and returns the most likely kind of dead page with its confidence;
+// the detection is also recorded on the content, see HarvestedResourceContent.DeadPageDetection
+func (d *DeadPageDetector) Detect(content *HarvestedResourceContent, o observe.Observatory, parentSpan opentracing.Span) *DeadPageDetection {
+	return d.detect(content, d.statusPolicy, o, parentSpan)
+}
+
+// detect is Detect with the soft 404 patterns of statusPolicy (the defaults when it's nil)
+func (d *DeadPageDetector) detect(content *HarvestedResourceContent, statusPolicy *HTTPStatusPolicy, o observe.Observatory, parentSpan opentracing.Span) *DeadPageDetection {
+	result := new(DeadPageDetection)
+	if content == nil || !content.IsHTML() {
+		return result
+	}
+	span := o.StartChildTrace("DetectDeadPage", parentSpan)
+	defer span.Finish()
+
+	var soft404, parked detectionSignals
+	soft404TitlePatterns, soft404BodyPatterns := statusPolicy.soft404Patterns()
+	for _, pattern := range soft404TitlePatterns {
+		if pattern.MatchString(content.titleElementText) {
+			soft404.add(soft404TitleSignalWeight, fmt.Sprintf("Title matched `%s`", pattern.String()))
+			break
+		}
+	}
+	for _, pattern := range soft404BodyPatterns {
+		if pattern.MatchString(content.bodyTextSample) {
+			soft404.add(soft404BodySignalWeight, fmt.Sprintf("Body matched `%s`", pattern.String()))
+			break
+		}
+	}
+	for _, pattern := range d.parkedPatterns {
+		if pattern.MatchString(content.bodyTextSample) || pattern.MatchString(content.titleElementText) {
+			parked.add(parkingTextSignalWeight, fmt.Sprintf("Text matched `%s`", pattern.String()))
+			break
+		}
+	}
+	if provider, found := d.parkingProvider(content); found {
+		parked.add(parkingHostSignalWeight, fmt.Sprintf("References parking provider %s", provider))
+	}
+
+	if d.probeRandomPath && content.url != nil {
+		probe := d.probe(content.url, o, span)
+		switch {
+		case probe == nil:
+			// the host couldn't be probed so there's nothing to compare against
+		case probe.statusCode >= 200 && probe.statusCode < 300 && sameDeadPageContent(content, probe.content):
+			soft404.add(probeMatchSignalWeight, "Matches the response to a random path on the same host")
+			if parked.confidence > 0 {
+				parked.add(probeMatchSignalWeight, "Matches the response to a random path on the same host")
+			}
+		case probe.statusCode >= 200 && probe.statusCode < 300:
+			soft404.add(probeAcceptedSignalWeight, "Host responds to a random path with an accepted status code")
+		case probe.statusCode == http.StatusNotFound || probe.statusCode == http.StatusGone:
+			// the host reports missing pages properly so a "not found" looking page is less likely to be one
+			soft404.confidence *= probeNotFoundDiscountRatio
+		}
+	}
+
+	if parked.confidence > 0 && parked.confidence >= soft404.confidence {
+		result.Confidence, result.Signals = parked.confidence, parked.signals
+		if result.Confidence >= d.threshold {
+			result.Kind = ParkedDomainPage
+		}
+	} else {
+		result.Confidence, result.Signals = soft404.confidence, soft404.signals
+		if result.Confidence >= d.threshold && result.Confidence > 0 {
+			result.Kind = Soft404Page
+		}
+	}
+	content.deadPage = result
+	span.LogFields(log.String("kind", result.Kind.String()), log.Float64("confidence", result.Confidence))
+	return result
+}
+
+// parkingProvider returns the parking provider host the content was served from or references
+func (d *DeadPageDetector) parkingProvider(content *HarvestedResourceContent) (string, bool) {
+	candidates := append([]string{urlText(content.url)}, content.embeddedSources...)
+	for _, candidate := range candidates {
+		u, err := url.Parse(candidate)
+		if err != nil || len(u.Hostname()) == 0 {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		for _, provider := range d.parkingProviderHosts {
+			if host == provider || strings.HasSuffix(host, "."+provider) {
+				return provider, true
+			}
+		}
+	}
+	return "", false
+}
+
+// probe retrieves a random path on the same host as u, once per host; the lock is only held to look up
+// and store the result so that a slow host doesn't hold up the detection of pages on other hosts
+func (d *DeadPageDetector) probe(u *url.URL, o observe.Observatory, parentSpan opentracing.Span) *deadPageProbe {
+	origin := u.Scheme + "://" + u.Host
+	d.probesMutex.Lock()
+	probe, found := d.probes[origin]
+	d.probesMutex.Unlock()
+	if found {
+		return probe
+	}
+
+	span := o.StartChildTrace("probeRandomPath", parentSpan)
+	defer span.Finish()
+
+	random := make([]byte, 12)
+	if _, err := cryptorand.Read(random); err != nil {
+		// without a random path the probe could hit a real page, so it's better not to probe at all
+		span.LogFields(log.Error(err))
+		return nil
+	}
+	probeURL, _ := url.Parse(origin + "/" + hex.EncodeToString(random))
+	span.LogFields(log.String("probeURL", probeURL.String()))
+
+	var result *deadPageProbe
+	resp, err := d.probeClient.Get(probeURL.String())
+	if err != nil {
+		span.LogFields(log.Error(err))
+	} else {
+		defer resp.Body.Close()
+		content := new(HarvestedResourceContent)
+		content.url = probeURL
+		content.metaPropertyTags = make(map[string]string)
+		content.contentType = resp.Header.Get("Content-Type")
+		if strings.HasPrefix(strings.ToLower(content.contentType), "text/html") {
+			content.mediaType = "text/html"
+			_, content.mediaTypeParams, _ = mime.ParseMediaType(content.contentType)
+			limitHTMLBody(resp, DefaultMaxHTMLBytes, false)
+			content.decodeHTMLBody(resp)
+			content.parsePageMetaData(probeURL, resp, o, span)
+		}
+		result = &deadPageProbe{statusCode: resp.StatusCode, content: content}
+	}
+	d.probesMutex.Lock()
+	d.probes[origin] = result
+	d.probesMutex.Unlock()
+	return result
+}
+
+// sameDeadPageContent returns true if both pages have the same title or body text
+func sameDeadPageContent(content, probe *HarvestedResourceContent) bool {
+	if len(content.titleElementText) > 0 && content.titleElementText == probe.titleElementText {
+		return true
+	}
+	return len(content.bodyTextSample) > 0 && content.bodyTextSample == probe.bodyTextSample
+}
+
+type detectionSignals struct {
+	confidence float64
+	signals    []string
+}
+
+func (s *detectionSignals) add(weight float64, signal string) {
+	s.confidence = 1 - (1-s.confidence)*(1-weight)
+	s.signals = append(s.signals, signal)
+}
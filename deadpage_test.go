@@ -0,0 +1,126 @@
+package harvester
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const parkedPage = `<html><head><title>example.com</title>
+	<script src="https://www.sedoparking.com/frmpark/example.com/park.js"></script></head>
+	<body><h1>example.com</h1><p>This domain may be for sale!</p></body></html>`
+
+const catchAllSoft404Page = `<html><head><title>Example</title></head>
+	<body><p>Sorry, the page you are looking for does not exist.</p></body></html>`
+
+// deadPageTestFixtures are served by a host which responds to random paths with a proper 404
+var deadPageTestFixtures = testFixtures{pages: map[string]string{"/soft-404": soft404TestPage}}
+
+type DeadPageSuite struct {
+	harvesterSuite
+	catchAll *httptest.Server
+}
+
+func (suite *DeadPageSuite) SetupSuite() {
+	suite.setupSuite(deadPageTestFixtures.handler())
+	suite.catchAll = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Path == "/parked" {
+			fmt.Fprint(w, parkedPage)
+			return
+		}
+		fmt.Fprint(w, catchAllSoft404Page)
+	}))
+	// the status policy's soft 404 detection is on to make sure the detector supersedes it
+	suite.ch = MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{StatusPolicy: &HTTPStatusPolicy{DetectSoft404: true}, DeadPageDetector: MakeDefaultDeadPageDetector()})
+}
+
+func (suite *DeadPageSuite) TearDownSuite() {
+	suite.catchAll.Close()
+	suite.harvesterSuite.TearDownSuite()
+}
+
+func (suite *DeadPageSuite) harvest(urlText string) *HarvestedResource {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s in a mock tweet", urlText), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return harvested.Resources[0]
+}
+
+func (suite *DeadPageSuite) TestSoft404MatchingRandomPathProbe() {
+	hr := suite.harvest(suite.catchAll.URL + "/articles/gone")
+	detection := hr.ResourceContent().DeadPageDetection()
+	suite.Require().NotNil(detection)
+	suite.Equal(Soft404Page, detection.Kind)
+	suite.InDelta(0.8, detection.Confidence, 0.001)
+	suite.Equal(2, len(detection.Signals))
+	suite.Equal(ResourceHTTPError, hr.Status())
+	suite.True(hr.IsGone())
+	var soft404Err *Soft404Error
+	suite.True(errors.As(hr.Err(), &soft404Err))
+}
+
+func (suite *DeadPageSuite) TestParkedDomain() {
+	hr := suite.harvest(suite.catchAll.URL + "/parked")
+	detection := hr.ResourceContent().DeadPageDetection()
+	suite.Equal(ParkedDomainPage, detection.Kind)
+	suite.True(detection.Confidence > 0.9)
+	var parkedErr *ParkedDomainError
+	suite.True(errors.As(hr.Err(), &parkedErr))
+	suite.Equal(detection.Confidence, parkedErr.Confidence)
+}
+
+func (suite *DeadPageSuite) TestProperNotFoundDiscountsSignals() {
+	// the test pages server responds to random paths with a real 404
+	hr := suite.harvest(suite.server.URL + "/soft-404")
+	detection := hr.ResourceContent().DeadPageDetection()
+	suite.Equal(LivePage, detection.Kind)
+	suite.InDelta(0.35, detection.Confidence, 0.001)
+	suite.Equal(ResourceResolved, hr.Status(), "The status policy's patterns shouldn't override the detector")
+}
+
+func (suite *DeadPageSuite) TestLivePage() {
+	hr := suite.harvest(suite.server.URL + "/og")
+	detection := hr.ResourceContent().DeadPageDetection()
+	suite.False(detection.IsDead())
+	suite.Equal(0.0, detection.Confidence)
+	suite.NoError(hr.Err())
+}
+
+func (suite *DeadPageSuite) TestStatusPolicyPatterns() {
+	pattern := regexp.MustCompile(`^Element Title$`)
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{StatusPolicy: &HTTPStatusPolicy{Soft404TitlePatterns: []*regexp.Regexp{pattern}}, DeadPageDetector: MakeDeadPageDetector(0.5, false, nil)})
+	defer ch.Close()
+	harvested := ch.HarvestResources(fmt.Sprintf("Test page %s/og in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	detection := harvested.Resources[0].ResourceContent().DeadPageDetection()
+	suite.Equal(Soft404Page, detection.Kind, "The detector should use the status policy's patterns")
+	suite.Equal([]string{"Title matched `^Element Title$`"}, detection.Signals)
+}
+
+func (suite *DeadPageSuite) TestDetectorStatusPolicyPatterns() {
+	pattern := regexp.MustCompile(`^Element Title$`)
+	detector := MakeDeadPageDetector(0.5, false, &HTTPStatusPolicy{Soft404TitlePatterns: []*regexp.Regexp{pattern}})
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{StatusPolicy: MakeDefaultHTTPStatusPolicy(), DeadPageDetector: detector})
+	defer ch.Close()
+	harvested := ch.HarvestResources(fmt.Sprintf("Test page %s/og in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	content := harvested.Resources[0].ResourceContent()
+	suite.Equal(Soft404Page, content.DeadPageDetection().Kind, "The detector's own status policy should win")
+
+	detection := detector.Detect(content, suite.observatory, suite.span)
+	suite.Equal([]string{"Title matched `^Element Title$`"}, detection.Signals)
+	detection = MakeDeadPageDetector(0.5, false, nil).Detect(content, suite.observatory, suite.span)
+	suite.Equal(LivePage, detection.Kind, "The default patterns shouldn't match")
+}
+
+func TestDeadPageSuite(t *testing.T) {
+	suite.Run(t, new(DeadPageSuite))
+}
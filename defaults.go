@@ -2,8 +2,10 @@ package harvester
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"regexp"
+	"strings"
 )
 
 type ignoreURLsRegExList []*regexp.Regexp
@@ -39,6 +41,27 @@ func (l removeParamsFromURLsRegExList) RemoveQueryParamFromResource(paramName st
 	return false, ""
 }
 
+// CanonicalURL returns a normalized version of the URL suitable for identity comparisons: the scheme and
+// host are lowercased, default ports and fragments are removed, empty paths become "/" and query
+// parameters are sorted by name
+func CanonicalURL(u *url.URL) string {
+	canonical := *u
+	canonical.Scheme = strings.ToLower(u.Scheme)
+	canonical.Host = strings.ToLower(u.Hostname())
+	if port := u.Port(); len(port) > 0 && !(canonical.Scheme == "http" && port == "80") && !(canonical.Scheme == "https" && port == "443") {
+		canonical.Host = net.JoinHostPort(canonical.Host, port)
+	} else if strings.Contains(canonical.Host, ":") {
+		canonical.Host = "[" + canonical.Host + "]"
+	}
+	canonical.Fragment = ""
+	canonical.RawFragment = ""
+	if len(canonical.Path) == 0 {
+		canonical.Path = "/"
+	}
+	canonical.RawQuery = u.Query().Encode()
+	return canonical.String()
+}
+
 // GetSimplifiedHostname returns the URL's hostname without 'www.' prefix
 func GetSimplifiedHostname(url *url.URL) string {
 	return defaultWebPrefixRegEx.ReplaceAllString(url.Hostname(), "")
@@ -0,0 +1,183 @@
+package harvester
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NetworkError is returned when a resource could not be retrieved because of a network failure
+// which isn't more specifically described by DNSError, TLSError or TimeoutError
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("unable to retrieve %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// DNSError is returned when the host of a resource could not be resolved
+type DNSError struct {
+	URL  string
+	Host string
+	Err  error
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("unable to resolve host %q of %s: %v", e.Host, e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DNSError) Unwrap() error {
+	return e.Err
+}
+
+// TLSError is returned when a secure connection to a resource could not be established (e.g. the
+// certificate was invalid or the handshake failed)
+type TLSError struct {
+	URL string
+	Err error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("unable to establish a secure connection to %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *TLSError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when a resource did not respond in time
+type TimeoutError struct {
+	URL string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out retrieving %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError is returned when a resource responded with an HTTP status code that isn't accepted;
+// Gone is true if the status code means the resource is permanently missing (see HTTPStatusPolicy)
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Gone       bool
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("Invalid HTTP Status Code %d", e.StatusCode)
+}
+
+// Soft404Error is returned when a resource responded with an accepted status code but its content
+// looks like a "page not found" page
+type Soft404Error struct {
+	URL        string
+	StatusCode int
+	Reason     string
+}
+
+func (e *Soft404Error) Error() string {
+	return fmt.Sprintf("Soft 404 (HTTP Status Code %d): %s", e.StatusCode, e.Reason)
+}
+
+// ParseError is returned when something about a resource could not be parsed; Subject describes what
+// failed to parse (e.g. "URL", "media type", "HTML" or "file type")
+type ParseError struct {
+	URL     string
+	Subject string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("unable to parse %s of %s: %v", e.Subject, e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParkedDomainError is returned when a resource's content looks like a domain parking landing page
+type ParkedDomainError struct {
+	URL        string
+	Confidence float64
+	Signals    []string
+}
+
+func (e *ParkedDomainError) Error() string {
+	return fmt.Sprintf("Parked domain (confidence %.2f): %s", e.Confidence, strings.Join(e.Signals, "; "))
+}
+
+// IgnoredByRuleError is returned when a resource was ignored because it matched an IgnoreDiscoveredResourceRule
+type IgnoredByRuleError struct {
+	URL    string
+	Reason string
+}
+
+func (e *IgnoredByRuleError) Error() string {
+	return e.Reason
+}
+
+// TooLargeError is returned when a resource's content exceeds a configured size limit
+type TooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("content of %s exceeds the limit of %d bytes", e.URL, e.Limit)
+}
+
+// classifyRequestError wraps an error returned by the HTTP client in the most specific error type
+func classifyRequestError(urlText string, err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &DNSError{URL: urlText, Host: dnsErr.Name, Err: err}
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return &TimeoutError{URL: urlText, Err: err}
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return &TLSError{URL: urlText, Err: err}
+	}
+
+	return &NetworkError{URL: urlText, Err: err}
+}
+
+// validateURLText returns a ParseError if urlText isn't an absolute HTTP(S) URL
+func validateURLText(urlText string) error {
+	u, err := url.Parse(urlText)
+	if err != nil {
+		return &ParseError{URL: urlText, Subject: "URL", Err: err}
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return &ParseError{URL: urlText, Subject: "URL", Err: fmt.Errorf("not an absolute HTTP(S) URL")}
+	}
+	return nil
+}
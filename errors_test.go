@@ -0,0 +1,135 @@
+package harvester
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsSuite struct {
+	harvesterSuite
+}
+
+func (suite *ErrorsSuite) SetupSuite() {
+	suite.setupSuite(testFixtures{}.handler())
+	ignoreRules := ignoreURLsRegExList{regexp.MustCompile(`/title-only$`)}
+	suite.ch = MakeContentHarvester(suite.observatory, ignoreRules, defaultCleanURLsRegExList, false)
+}
+
+func (suite *ErrorsSuite) harvest(urlText string) *HarvestedResource {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s in a mock tweet", urlText), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return harvested.Resources[0]
+}
+
+func (suite *ErrorsSuite) TestHTTPStatusError() {
+	hr := suite.harvest(suite.server.URL + "/missing")
+	var statusErr *HTTPStatusError
+	suite.True(errors.As(hr.Err(), &statusErr))
+	suite.Equal(404, statusErr.StatusCode)
+	suite.Equal("Invalid HTTP Status Code 404", statusErr.Error())
+}
+
+func (suite *ErrorsSuite) TestIgnoredByRuleError() {
+	hr := suite.harvest(suite.server.URL + "/title-only")
+	var ignoredErr *IgnoredByRuleError
+	suite.True(errors.As(hr.Err(), &ignoredErr))
+	suite.Equal("Matched Ignore Rule `/title-only$`", ignoredErr.Reason)
+}
+
+func (suite *ErrorsSuite) TestParseError() {
+	hr := suite.harvest("ftp://example.com/file.txt")
+	var parseErr *ParseError
+	suite.True(errors.As(hr.Err(), &parseErr))
+	suite.Equal("URL", parseErr.Subject)
+	isURLValid, _ := hr.IsValid()
+	suite.False(isURLValid)
+}
+
+func (suite *ErrorsSuite) TestNoErrorForHarvestedPage() {
+	hr := suite.harvest(suite.server.URL + "/og")
+	suite.NoError(hr.Err())
+	suite.NoError(hr.ResourceContent().Err())
+}
+
+func (suite *ErrorsSuite) TestStatusTransitions() {
+	statuses := func(hr *HarvestedResource) []ResourceStatus {
+		var result []ResourceStatus
+		for _, change := range hr.StatusHistory() {
+			result = append(result, change.Status)
+		}
+		return result
+	}
+
+	hr := suite.harvest(suite.server.URL + "/og")
+	suite.Equal(ResourceResolved, hr.Status())
+	suite.Equal([]ResourceStatus{ResourceDiscovered, ResourceResolved}, statuses(hr))
+
+	hr = suite.harvest(suite.server.URL + "/missing")
+	suite.Equal(ResourceHTTPError, hr.Status())
+	suite.Equal([]ResourceStatus{ResourceDiscovered, ResourceHTTPError}, statuses(hr))
+	suite.Equal("Invalid HTTP Status Code 404", hr.StatusHistory()[1].Reason)
+	isIgnored, _ := hr.IsIgnored()
+	suite.False(isIgnored, "Failed resources should not be flagged as ignored")
+
+	hr = suite.harvest(suite.server.URL + "/title-only")
+	suite.Equal(ResourceIgnoredByRule, hr.Status())
+
+	hr = suite.harvest("ftp://example.com/file.txt")
+	suite.Equal(ResourceUnreachable, hr.Status())
+	suite.True(hr.Status().IsFailure())
+}
+
+func (suite *ErrorsSuite) TestSerializeDispatchesOnStatus() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Pages %[1]s/og %[1]s/missing %[1]s/title-only ftp://example.com/file.txt", suite.server.URL), suite.span)
+	skipped := make(map[ResourceStatus]int)
+	var invalidURL, invalidDest, ignored int
+	report := harvested.SerializeWithReport(HarvestedResourcesSerializer{
+		GetKeys: func(hr *HarvestedResource) *HarvestedResourceKeys {
+			return CreateHarvestedResourceKeys(hr, func(random uint32, try int) bool { return false })
+		},
+		GetTemplate: func(keys *HarvestedResourceKeys) (*template.Template, error) {
+			return NewTemplate("status").Parse("{{ .Keys.Title }}")
+		},
+		GetTemplateParams:    func(keys *HarvestedResourceKeys) *map[string]interface{} { return nil },
+		GetWriter:            func(keys *HarvestedResourceKeys) io.Writer { return ioutil.Discard },
+		HandleInvalidURL:     func(hr *HarvestedResource) { invalidURL++ },
+		HandleInvalidURLDest: func(hr *HarvestedResource) { invalidDest++ },
+		HandleIgnoredURL:     func(hr *HarvestedResource) { ignored++ },
+		HandleSkipped:        func(hr *HarvestedResource, status ResourceStatus) { skipped[status]++ },
+	})
+	suite.NoError(report.Err())
+	suite.Equal(1, len(report.WithOutcome(SerializeWritten)))
+	suite.Equal(map[ResourceStatus]int{ResourceHTTPError: 1, ResourceIgnoredByRule: 1, ResourceUnreachable: 1}, skipped)
+	suite.Equal([]int{1, 1, 1}, []int{invalidURL, invalidDest, ignored})
+}
+
+func (suite *ErrorsSuite) TestClassifyRequestError() {
+	urlText := "https://unresolvable.example"
+	var dnsErr *DNSError
+	suite.True(errors.As(classifyRequestError(urlText, &net.DNSError{Name: "unresolvable.example", Err: "no such host"}), &dnsErr))
+	suite.Equal("unresolvable.example", dnsErr.Host)
+
+	var timeoutErr *TimeoutError
+	suite.True(errors.As(classifyRequestError(urlText, &net.DNSError{Name: "slow.example", IsTimeout: true}), &dnsErr))
+	suite.True(errors.As(classifyRequestError(urlText, &net.OpError{Op: "dial", Err: timeoutError{}}), &timeoutErr))
+
+	var networkErr *NetworkError
+	suite.True(errors.As(classifyRequestError(urlText, errors.New("connection reset")), &networkErr))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "i/o timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestErrorsSuite(t *testing.T) {
+	suite.Run(t, new(ErrorsSuite))
+}
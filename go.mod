@@ -1,24 +1,34 @@
 module github.com/lectio/harvester
 
-go 1.12
+go 1.20
 
 require (
 	github.com/Machiel/slugify v1.0.1
-	github.com/PuerkitoBio/goquery v1.5.0 // indirect
-	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
-	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/andybalholm/brotli v1.0.4
 	github.com/h2non/filetype v1.0.8
-	github.com/julianshen/go-readability v0.0.0-20160929030430-accf5123e283 // indirect
+	github.com/julianshen/go-readability v0.0.0-20160929030430-accf5123e283
 	github.com/julianshen/og v0.0.0-20170124022037-897162c55567
 	github.com/lectio/observe v0.0.0-20190330161145-24f6fc031cdd
-	github.com/mvdan/xurls v1.1.0 // indirect
+	github.com/ledongthuc/pdf v0.0.0-20220302134840-0c2507a12d80
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/opentracing/opentracing-go v1.1.0
-	github.com/pkg/errors v0.8.1 // indirect
+	github.com/rainycape/unidecode v0.0.0-20150907023854-cb7f23ec59be
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/net v0.0.0-20190328230028-74de082e2cca
+	golang.org/x/text v0.3.0
+	mvdan.cc/xurls v1.1.0
+)
+
+require (
+	github.com/PuerkitoBio/goquery v1.5.0 // indirect
+	github.com/andybalholm/cascadia v1.0.0 // indirect
+	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/mvdan/xurls v1.1.0 // indirect
+	github.com/pkg/errors v0.8.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/uber-go/atomic v1.3.2 // indirect
 	github.com/uber/jaeger-client-go v2.16.0+incompatible // indirect
 	github.com/uber/jaeger-lib v2.0.0+incompatible // indirect
 	go.uber.org/atomic v1.3.2 // indirect
-	golang.org/x/net v0.0.0-20190328230028-74de082e2cca
-	mvdan.cc/xurls v1.1.0
 )
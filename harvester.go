@@ -1,10 +1,14 @@
 package harvester
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"strings"
 	"text/template"
 	"time"
 
@@ -38,96 +42,314 @@ type ContentHarvester struct {
 	followHTMLRedirects bool
 	ignoreResourceRule  IgnoreDiscoveredResourceRule
 	cleanResourceRule   CleanDiscoveredResourceRule
+	retentionPolicy     DownloadRetentionPolicy
+	statusPolicy        *HTTPStatusPolicy
+	deadPageDetector    *DeadPageDetector
+	extractArticles     bool
+	oEmbedClient        *OEmbedClient
+	pdfInspector        *PDFInspector
+	maxHTMLBytes        int64
+	inspectHeadOnly     bool
+	retainHTML          bool
+	retainedHTMLBudget  *htmlMemoryBudget
+	blobStore           *BlobStore
+	workDir             string
+	ownsWorkDir         bool
 	contentEncountered  []*HarvestedResourceContent
 }
 
+// ContentHarvesterOptions are the less commonly needed settings of a content harvester
+type ContentHarvesterOptions struct {
+	// FollowHTMLRedirects replaces resources that request an HTML <meta http-equiv='refresh'> redirect
+	// with the resource they redirect to
+	FollowHTMLRedirects bool
+
+	// WorkDir is where downloads are stored; when empty, a temporary directory is created on the first
+	// download and removed by Close (unless it still contains retained downloads)
+	WorkDir string
+
+	// RetentionPolicy decides which downloads are kept by Close; when nil, only downloads that were
+	// explicitly retained (see DownloadedContent.Retain and MoveTo) are kept
+	RetentionPolicy DownloadRetentionPolicy
+
+	// BlobStore, when not nil, keeps every download under its SHA-256 digest instead of a temporary name
+	BlobStore *BlobStore
+
+	// StatusPolicy decides which HTTP responses are harvested; when nil, MakeDefaultHTTPStatusPolicy is used
+	StatusPolicy *HTTPStatusPolicy
+
+	// DeadPageDetector, when not nil, is run on HTML content; pages it flags as soft 404s or parked
+	// domains are treated as gone. It supersedes StatusPolicy's DetectSoft404 and matches pages against the
+	// soft 404 patterns of its own status policy or, when it was made without one, of StatusPolicy.
+	DeadPageDetector *DeadPageDetector
+
+	// ExtractArticles extracts the main content (see Article) of every HTML resource
+	ExtractArticles bool
+
+	// MaxHTMLBytes is how much decompressed HTML is inspected per resource; when zero, DefaultMaxHTMLBytes
+	// is used and when negative there's no limit
+	MaxHTMLBytes int64
+
+	// InspectHeadOnly stops reading HTML after </head>, see ContentDetectionOptions.HeadOnly
+	InspectHeadOnly bool
+
+	// RetainHTML keeps the HTML of every page available through HarvestedResourceContent.HTML until Close;
+	// once the pages together take up RetainHTMLMemoryBytes (DefaultRetainedHTMLMemoryBytes when zero),
+	// further pages are kept in WorkDir
+	RetainHTML            bool
+	RetainHTMLMemoryBytes int64
+
+	// OEmbedClient, when not nil, attaches an oEmbed response (see HarvestedResource.OEmbed) to every
+	// resolved resource with an allowed provider
+	OEmbedClient *OEmbedClient
+
+	// PDFInspector, when not nil, reads the metadata and text of downloaded PDFs so that they get a title and
+	// slug, see HarvestedResourceContent.PDF
+	PDFInspector *PDFInspector
+}
+
 // HarvestedResources is the list of URLs discovered in a piece of content
 type HarvestedResources struct {
 	Content   string
 	Resources []*HarvestedResource
 }
 
-// HarvestedResourcesSerializer contains callbacks for custom serialization of resources and content
+// HarvestedResourcesSerializer contains callbacks for custom serialization of resources and content.
+// Templates returned by GetTemplate are executed as they are, so templates which reference the helper
+// functions (see TemplateFuncs) must be parsed with NewTemplate or ParseTemplateFiles.
+// Only resolved resources (and those whose content could not be inspected) are serialized; every other
+// resource is passed to HandleSkipped and to the callback matching its status.
 type HarvestedResourcesSerializer struct {
 	GetKeys              func(*HarvestedResource) *HarvestedResourceKeys
 	GetTemplate          func(*HarvestedResourceKeys) (*template.Template, error)
 	GetTemplateParams    func(*HarvestedResourceKeys) *map[string]interface{}
 	GetWriter            func(*HarvestedResourceKeys) io.Writer
-	HandleInvalidURL     func(*HarvestedResource)
-	HandleInvalidURLDest func(*HarvestedResource)
-	HandleIgnoredURL     func(*HarvestedResource)
+	HandleInvalidURL     func(*HarvestedResource) // ResourceUnreachable and ResourceDiscovered
+	HandleInvalidURLDest func(*HarvestedResource) // ResourceHTTPError
+	HandleIgnoredURL     func(*HarvestedResource) // ResourceIgnoredByRule
+
+	// HandleSkipped, when not nil, is called with the status of every resource that isn't serialized
+	HandleSkipped func(*HarvestedResource, ResourceStatus)
+
+	// OpenWriter, when not nil, is used instead of GetWriter; it may fail and may return an io.Closer
+	// (e.g. an *os.File) which is closed once the resource has been written
+	OpenWriter func(*HarvestedResourceKeys) (io.Writer, io.Closer, error)
+
+	// ContinueOnError keeps serializing the remaining resources after a resource fails
+	ContinueOnError bool
+}
+
+// SerializeOutcome describes what happened to a single resource during serialization
+type SerializeOutcome int
+
+const (
+	// SerializeWritten means the resource was written successfully
+	SerializeWritten SerializeOutcome = iota
+
+	// SerializeSkippedInvalidURL means the resource was skipped because it was unreachable (or never retrieved)
+	SerializeSkippedInvalidURL
+
+	// SerializeSkippedInvalidDest means the resource was skipped because it responded with an HTTP error
+	SerializeSkippedInvalidDest
+
+	// SerializeSkippedIgnored means the resource was skipped because of an ignore rule
+	SerializeSkippedIgnored
+
+	// SerializeFailed means the resource could not be written, see SerializedResource.Error
+	SerializeFailed
+)
+
+func (o SerializeOutcome) String() string {
+	switch o {
+	case SerializeWritten:
+		return "written"
+	case SerializeSkippedInvalidURL:
+		return "skipped-invalid-url"
+	case SerializeSkippedInvalidDest:
+		return "skipped-invalid-destination"
+	case SerializeSkippedIgnored:
+		return "skipped-ignored"
+	case SerializeFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("SerializeOutcome(%d)", int(o))
+}
+
+// SerializedResource records the serialization outcome of a single resource
+type SerializedResource struct {
+	Resource *HarvestedResource
+	Keys     *HarvestedResourceKeys // nil when the resource was skipped
+	Outcome  SerializeOutcome
+	Error    error // only set when Outcome is SerializeFailed
+}
+
+// SerializeReport records the outcome of every resource that was serialized
+type SerializeReport struct {
+	Results []*SerializedResource
+}
+
+// WithOutcome returns the results which had the given outcome
+func (report *SerializeReport) WithOutcome(outcome SerializeOutcome) []*SerializedResource {
+	var result []*SerializedResource
+	for _, sr := range report.Results {
+		if sr.Outcome == outcome {
+			result = append(result, sr)
+		}
+	}
+	return result
+}
+
+// Err returns nil if no resources failed, the error itself if only one failed or an error
+// summarizing all of the failures otherwise
+func (report *SerializeReport) Err() error {
+	failed := report.WithOutcome(SerializeFailed)
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0].Error
+	}
+
+	messages := make([]string, 0, len(failed))
+	for _, sr := range failed {
+		messages = append(messages, fmt.Sprintf("%s: %v", sr.Resource.OriginalURLText(), sr.Error))
+	}
+	return fmt.Errorf("%d resources failed to serialize: %s", len(failed), strings.Join(messages, "; "))
 }
 
 // Serialize writes harvested content out to a storage device
 func (r *HarvestedResources) Serialize(serializer HarvestedResourcesSerializer) error {
+	return r.SerializeWithReport(serializer).Err()
+}
+
+// SerializeWithReport writes harvested content out to a storage device and reports what happened to
+// each resource; unless serializer.ContinueOnError is true it stops at the first failure
+func (r *HarvestedResources) SerializeWithReport(serializer HarvestedResourcesSerializer) *SerializeReport {
+	report := new(SerializeReport)
 	for _, hr := range r.Resources {
-		isURLValid, isDestValid := hr.IsValid()
-		if !isURLValid {
-			if serializer.HandleInvalidURL != nil {
-				serializer.HandleInvalidURL(hr)
-			}
-			continue
+		sr := &SerializedResource{Resource: hr}
+		report.Results = append(report.Results, sr)
+
+		var handler func(*HarvestedResource)
+		switch hr.Status() {
+		case ResourceResolved, ResourceContentError:
+			// serialized below
+		case ResourceIgnoredByRule:
+			sr.Outcome, handler = SerializeSkippedIgnored, serializer.HandleIgnoredURL
+		case ResourceHTTPError:
+			sr.Outcome, handler = SerializeSkippedInvalidDest, serializer.HandleInvalidURLDest
+		default:
+			sr.Outcome, handler = SerializeSkippedInvalidURL, serializer.HandleInvalidURL
 		}
-		if !isDestValid {
-			if serializer.HandleInvalidURLDest != nil {
-				serializer.HandleInvalidURLDest(hr)
+		if sr.Outcome != SerializeWritten {
+			if serializer.HandleSkipped != nil {
+				serializer.HandleSkipped(hr, hr.Status())
+			}
+			if handler != nil {
+				handler(hr)
 			}
 			continue
 		}
 
-		isIgnored, _ := hr.IsIgnored()
-		if isIgnored {
-			if serializer.HandleIgnoredURL != nil {
-				serializer.HandleIgnoredURL(hr)
+		sr.Keys = serializer.GetKeys(hr)
+		sr.Error = r.serializeResource(serializer, hr, sr.Keys)
+		if sr.Error != nil {
+			sr.Outcome = SerializeFailed
+			if !serializer.ContinueOnError {
+				break
 			}
 			continue
 		}
+		sr.Outcome = SerializeWritten
+	}
 
-		keys := serializer.GetKeys(hr)
-		t, tmplErr := serializer.GetTemplate(keys)
-		if tmplErr != nil {
-			return tmplErr
-		}
-		params := serializer.GetTemplateParams(keys)
-		writer := serializer.GetWriter(keys)
-
-		isCleaned, _ := hr.IsCleaned()
-		finalURL, resolvedURL, _ := hr.GetURLs()
-		err := t.Execute(writer, struct {
-			Content     string
-			Resource    *HarvestedResource
-			HarvestedOn time.Time
-			IsCleaned   bool
-			FinalURL    string
-			ResolvedURL string
-			Params      *map[string]interface{}
-			Slug        string
-		}{
-			r.Content,
-			hr,
-			hr.harvestedOn,
-			isCleaned,
-			finalURL.String(),
-			resolvedURL.String(),
-			params,
-			keys.Slug(),
-		})
+	return report
+}
+
+func (r *HarvestedResources) serializeResource(serializer HarvestedResourcesSerializer, hr *HarvestedResource, keys *HarvestedResourceKeys) (err error) {
+	t, tmplErr := serializer.GetTemplate(keys)
+	if tmplErr != nil {
+		return tmplErr
+	}
+	params := serializer.GetTemplateParams(keys)
+
+	var writer io.Writer
+	if serializer.OpenWriter != nil {
+		var closer io.Closer
+		writer, closer, err = serializer.OpenWriter(keys)
 		if err != nil {
 			return err
 		}
+		if closer != nil {
+			defer func() {
+				if closeErr := closer.Close(); closeErr != nil && err == nil {
+					err = closeErr
+				}
+			}()
+		}
+	} else {
+		writer = serializer.GetWriter(keys)
 	}
 
-	return nil
+	isCleaned, _ := hr.IsCleaned()
+	finalURL, resolvedURL, _ := hr.GetURLs()
+	var article *Article
+	if hr.resourceContent != nil {
+		article = hr.resourceContent.article
+	}
+	return t.Execute(writer, struct {
+		Content     string
+		Resource    *HarvestedResource
+		Keys        *HarvestedResourceKeys
+		Article     *Article // nil unless articles were extracted
+		HarvestedOn time.Time
+		IsCleaned   bool
+		FinalURL    string
+		ResolvedURL string
+		Params      *map[string]interface{}
+		Slug        string
+	}{
+		r.Content,
+		hr,
+		keys,
+		article,
+		hr.harvestedOn,
+		isCleaned,
+		finalURL.String(),
+		resolvedURL.String(),
+		params,
+		keys.Slug(),
+	})
 }
 
 // MakeContentHarvester prepares a content harvester
 func MakeContentHarvester(observatory observe.Observatory, ignoreResourceRule IgnoreDiscoveredResourceRule, cleanResourceRule CleanDiscoveredResourceRule, followHTMLRedirects bool) *ContentHarvester {
+	return MakeContentHarvesterWithOptions(observatory, ignoreResourceRule, cleanResourceRule, ContentHarvesterOptions{FollowHTMLRedirects: followHTMLRedirects})
+}
+
+// MakeContentHarvesterWithOptions prepares a content harvester using the given options
+func MakeContentHarvesterWithOptions(observatory observe.Observatory, ignoreResourceRule IgnoreDiscoveredResourceRule, cleanResourceRule CleanDiscoveredResourceRule, options ContentHarvesterOptions) *ContentHarvester {
 	result := new(ContentHarvester)
 	result.observatory = observatory
 	result.discoverURLsRegEx = xurls.Relaxed
 	result.ignoreResourceRule = ignoreResourceRule
 	result.cleanResourceRule = cleanResourceRule
-	result.followHTMLRedirects = followHTMLRedirects
+	result.followHTMLRedirects = options.FollowHTMLRedirects
+	result.workDir = options.WorkDir
+	result.retentionPolicy = options.RetentionPolicy
+	result.blobStore = options.BlobStore
+	result.statusPolicy = options.StatusPolicy
+	result.deadPageDetector = options.DeadPageDetector
+	result.extractArticles = options.ExtractArticles
+	result.oEmbedClient = options.OEmbedClient
+	result.pdfInspector = options.PDFInspector
+	result.maxHTMLBytes = options.MaxHTMLBytes
+	result.inspectHeadOnly = options.InspectHeadOnly
+	result.retainHTML = options.RetainHTML
+	result.retainedHTMLBudget = newHTMLMemoryBudget(options.RetainHTMLMemoryBytes)
+	if result.statusPolicy == nil {
+		result.statusPolicy = MakeDefaultHTTPStatusPolicy()
+	}
 	return result
 }
 
@@ -136,18 +358,116 @@ func MakeDefaultContentHarvester(observatory observe.Observatory) *ContentHarves
 	return MakeContentHarvester(observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, true)
 }
 
-// Close will clean up resources, mainly temporary files that were created for downloaded resources
+// WorkDir returns the directory downloads are stored in, creating a temporary one if necessary
+func (h *ContentHarvester) WorkDir() (string, error) {
+	if len(h.workDir) > 0 {
+		return h.workDir, nil
+	}
+	dir, err := ioutil.TempDir("", "harvester-")
+	if err != nil {
+		return "", err
+	}
+	h.workDir = dir
+	h.ownsWorkDir = true
+	return dir, nil
+}
+
+// Downloads returns all the content that was downloaded by this harvester and not yet cleaned up
+func (h *ContentHarvester) Downloads() []*DownloadedContent {
+	var result []*DownloadedContent
+	for _, content := range h.contentEncountered {
+		if content.downloaded != nil && len(content.downloaded.DestPath) > 0 {
+			result = append(result, content.downloaded)
+		}
+	}
+	return result
+}
+
+// Close will clean up resources, mainly temporary files that were created for downloaded resources,
+// see Cleanup for the details and to find out whether any of the files could not be deleted
 func (h *ContentHarvester) Close() {
+	h.Cleanup()
+}
 
+// Cleanup deletes downloads unless they were retained explicitly or by the harvester's retention policy,
+// and the HTML that was retained in memory or in files (see ContentHarvesterOptions.RetainHTML), returning
+// the first error
+func (h *ContentHarvester) Cleanup() error {
+	var firstErr error
+	for _, dc := range h.Downloads() {
+		if !dc.IsRetained() && h.retentionPolicy != nil {
+			if retain, _ := h.retentionPolicy.RetainDownload(dc); retain {
+				dc.Retain()
+			}
+		}
+		if dc.IsRetained() {
+			continue
+		}
+		if err := os.Remove(dc.DestPath); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, content := range h.contentEncountered {
+		if err := content.retainedHTML.remove(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	h.contentEncountered = nil
+
+	if h.ownsWorkDir {
+		// only succeeds if no retained downloads were left in the directory
+		if err := os.Remove(h.workDir); err == nil || os.IsNotExist(err) {
+			h.workDir = ""
+			h.ownsWorkDir = false
+		}
+	}
+	return firstErr
 }
 
 // detectContentType will figure out what kind of destination content we're dealing with
 func (h *ContentHarvester) detectResourceContent(url *url.URL, resp *http.Response, o observe.Observatory, parentSpan opentracing.Span) *HarvestedResourceContent {
-	result := DetectHarvestedResourceContent(url, resp, o, parentSpan)
+	options := ContentDetectionOptions{BlobStore: h.blobStore, ExtractArticle: h.extractArticles,
+		MaxHTMLBytes: h.maxHTMLBytes, HeadOnly: h.inspectHeadOnly, RetainHTML: h.retainHTML,
+		PDFInspector: h.pdfInspector, retainedHTMLBudget: h.retainedHTMLBudget}
+	// the working directory is only created once something is written to it
+	options.resolveDownloadDir = h.WorkDir
+	result := DetectHarvestedResourceContentWithOptions(url, resp, o, parentSpan, options)
 	h.contentEncountered = append(h.contentEncountered, result)
 	return result
 }
 
+// detectDeadPage runs the dead page detector, if there is one, on the content using the detector's
+// status policy or, when it has none, the harvester's
+func (h *ContentHarvester) detectDeadPage(content *HarvestedResourceContent, parentSpan opentracing.Span) *DeadPageDetection {
+	if h.deadPageDetector == nil {
+		return nil
+	}
+	statusPolicy := h.deadPageDetector.statusPolicy
+	if statusPolicy == nil {
+		statusPolicy = h.statusPolicy
+	}
+	return h.deadPageDetector.detect(content, statusPolicy, h.observatory, parentSpan)
+}
+
+// isSoft404 applies the status policy's soft 404 patterns unless there's a dead page detector, which
+// already weighed the same patterns against its other signals (e.g. the random path probe)
+func (h *ContentHarvester) isSoft404(content *HarvestedResourceContent) (bool, string) {
+	if h.deadPageDetector != nil {
+		return false, ""
+	}
+	return h.statusPolicy.IsSoft404(content)
+}
+
+// fetchOEmbed retrieves the oEmbed response for a resource if there's an oEmbed client; failures are
+// only logged because the resource itself was harvested
+func (h *ContentHarvester) fetchOEmbed(url *url.URL, content *HarvestedResourceContent, parentSpan opentracing.Span) *OEmbed {
+	if h.oEmbedClient == nil {
+		return nil
+	}
+	result, _ := h.oEmbedClient.Fetch(url, content, h.observatory, parentSpan)
+	return result
+}
+
 // HarvestResources discovers URLs within content and returns what was found
 func (h *ContentHarvester) HarvestResources(content string, parentSpan opentracing.Span) *HarvestedResources {
 	span := h.observatory.StartChildTrace("HarvestResources", parentSpan)
@@ -0,0 +1,133 @@
+package harvester
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Base32Alphabet is the lowercase RFC 4648 base32 alphabet, safe for case-insensitive file systems
+const Base32Alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+// Base62Alphabet is the alphanumeric alphabet, useful for short case-sensitive keys
+const Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// maxResourceIDTries is the number of times a strategy tries to find an ID that doesn't already exist
+const maxResourceIDTries = 10000
+
+// ResourceIDStrategy generates the unique identity of a harvested resource. The numeric ID is passed
+// to the KeyExists callback for collision detection and the text is the ID as it should be stored.
+type ResourceIDStrategy interface {
+	GenerateResourceID(hr *HarvestedResource, existsFn KeyExists) (uint32, string)
+}
+
+// RandomResourceIDStrategy generates a random identifier each time it's called, this is the default
+type RandomResourceIDStrategy struct{}
+
+// GenerateResourceID returns a random ID that doesn't already exist
+func (s RandomResourceIDStrategy) GenerateResourceID(hr *HarvestedResource, existsFn KeyExists) (uint32, string) {
+	id := generateUniqueID(existsFn)
+	return id, strconv.FormatUint(uint64(id), 10)
+}
+
+// ContentAddressedResourceIDStrategy generates deterministic identifiers by hashing the canonical
+// version of the resource's final URL so that the same URL always gets the same ID
+type ContentAddressedResourceIDStrategy struct {
+	length   int
+	alphabet string
+}
+
+// MakeContentAddressedResourceIDStrategy prepares a deterministic ID strategy whose text IDs are
+// length characters long and only use characters from alphabet (e.g. Base32Alphabet or Base62Alphabet).
+// The alphabet must have at least two distinct ASCII characters and the length can't be more than the
+// number of digits a SHA-256 digest has in that base.
+func MakeContentAddressedResourceIDStrategy(length int, alphabet string) (*ContentAddressedResourceIDStrategy, error) {
+	if len(alphabet) < 2 {
+		return nil, fmt.Errorf("ID alphabet %q must have at least two characters", alphabet)
+	}
+	seen := make(map[rune]bool)
+	for _, r := range alphabet {
+		if r >= utf8.RuneSelf {
+			return nil, fmt.Errorf("ID alphabet %q may only have ASCII characters", alphabet)
+		}
+		if seen[r] {
+			return nil, fmt.Errorf("ID alphabet %q has %q more than once", alphabet, r)
+		}
+		seen[r] = true
+	}
+	if maxLength := digestDigits(len(alphabet)); length < 1 || length > maxLength {
+		return nil, fmt.Errorf("ID length %d must be between 1 and %d for a %d character alphabet", length, maxLength, len(alphabet))
+	}
+
+	result := new(ContentAddressedResourceIDStrategy)
+	result.length = length
+	result.alphabet = alphabet
+	return result, nil
+}
+
+// MakeDefaultContentAddressedResourceIDStrategy prepares a deterministic ID strategy with 12 base32 characters
+func MakeDefaultContentAddressedResourceIDStrategy() *ContentAddressedResourceIDStrategy {
+	result, _ := MakeContentAddressedResourceIDStrategy(12, Base32Alphabet)
+	return result
+}
+
+// digestDigits returns the number of digits needed to write any SHA-256 digest in the given base
+func digestDigits(base int) int {
+	value := new(big.Int).Lsh(big.NewInt(1), sha256.Size*8)
+	value.Sub(value, big.NewInt(1))
+	divisor := big.NewInt(int64(base))
+	digits := 0
+	for value.Sign() > 0 {
+		value.Quo(value, divisor)
+		digits++
+	}
+	return digits
+}
+
+// GenerateResourceID returns the hash of the resource's canonical URL; if the ID already exists the
+// hash is salted with the try number so that collisions are still resolved deterministically
+func (s *ContentAddressedResourceIDStrategy) GenerateResourceID(hr *HarvestedResource, existsFn KeyExists) (uint32, string) {
+	canonical := hr.OriginalURLText()
+	if hr.finalURL != nil {
+		canonical = CanonicalURL(hr.finalURL)
+	}
+
+	var id uint32
+	var text string
+	for try := 0; try < maxResourceIDTries; try++ {
+		input := canonical
+		if try > 0 {
+			input = fmt.Sprintf("%s#%d", canonical, try)
+		}
+		id, text = s.hash(input)
+		if !existsFn(id, try) {
+			return id, text
+		}
+	}
+
+	// give up after max tries, not much we can do
+	return id, text
+}
+
+// hash returns the low-order digits of the input's digest as the text ID and the low 32 bits of the
+// value of those digits as the numeric ID, so that IDs whose text collides also collide in KeyExists
+func (s *ContentAddressedResourceIDStrategy) hash(input string) (uint32, string) {
+	digest := sha256.Sum256([]byte(input))
+
+	base := big.NewInt(int64(len(s.alphabet)))
+	value := new(big.Int).SetBytes(digest[:])
+	value.Mod(value, new(big.Int).Exp(base, big.NewInt(int64(s.length)), nil))
+	id := uint32(new(big.Int).And(value, big.NewInt(math.MaxUint32)).Uint64())
+
+	mod := new(big.Int)
+	text := make([]byte, 0, s.length)
+	// digests with leading zero digits are padded so every ID has the same length
+	for len(text) < s.length {
+		value.DivMod(value, base, mod)
+		text = append(text, s.alphabet[mod.Int64()])
+	}
+	return id, string(text)
+}
@@ -3,20 +3,39 @@ package harvester
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/Machiel/slugify"
 	"github.com/julianshen/og"
 )
 
 // HarvestedResourceKeys tracks the keys for a single URL that was discovered in content.
 // Keys allow the URL to be identified in a database, key value store, etc.
 type HarvestedResourceKeys struct {
-	hr       *HarvestedResource
-	uniqueID uint32
-	pageInfo *og.PageInfo
-	piError  error
+	hr          *HarvestedResource
+	uniqueID    uint32
+	uniqueKey   string
+	slug        string
+	title       string
+	description string
+	images      []string
+	pageInfo    *og.PageInfo // only set when the network fallback was allowed and used
+	piError     error
+}
+
+// HarvestedResourceKeysOptions controls how keys are created for a resource
+type HarvestedResourceKeysOptions struct {
+	// AllowNetworkFallback permits an extra HTTP request to the resource's final URL
+	// when the harvested content does not have a title; by default keys are created
+	// offline, only from the content already captured while harvesting.
+	AllowNetworkFallback bool
+
+	// IDStrategy generates the resource's unique identity; when nil, a RandomResourceIDStrategy is used
+	IDStrategy ResourceIDStrategy
+
+	// SlugStrategy generates the resource's slug; when nil, the default FallbackSlugStrategy is used
+	SlugStrategy SlugStrategy
 }
 
 // HarvestedResource returns the underlying resource the keys were generated for
@@ -34,17 +53,39 @@ func (keys *HarvestedResourceKeys) UniqueIDText(format string) string {
 	return fmt.Sprintf(format, keys.uniqueID)
 }
 
-// IsValid returns true if there are no errors
+// UniqueKey returns the unique identity key as text, as generated by the ID strategy
+func (keys *HarvestedResourceKeys) UniqueKey() string {
+	return keys.uniqueKey
+}
+
+// IsValid returns true if there are no errors; a missing title is not an error, see HasTitle
 func (keys *HarvestedResourceKeys) IsValid() bool {
 	return keys.piError == nil
 }
 
-// Slug returns the title of the content
+// Title returns the title of the content
+func (keys *HarvestedResourceKeys) Title() string {
+	return keys.title
+}
+
+// HasTitle returns true if a title was found in the content (or through the network fallback)
+func (keys *HarvestedResourceKeys) HasTitle() bool {
+	return len(keys.title) > 0
+}
+
+// Description returns the description of the content, if one was found
+func (keys *HarvestedResourceKeys) Description() string {
+	return keys.description
+}
+
+// Images returns the URLs of the images associated with the content, if any were found
+func (keys *HarvestedResourceKeys) Images() []string {
+	return keys.images
+}
+
+// Slug returns the slug generated from the title of the content (or its fallbacks)
 func (keys *HarvestedResourceKeys) Slug() string {
-	if keys.piError == nil {
-		return slugify.Slugify(keys.pageInfo.Title)
-	}
-	return "Error getting PageInfo"
+	return keys.slug
 }
 
 // KeyExists is a function passed in that checks whether a key already exists
@@ -70,22 +111,111 @@ func generateUniqueID(existsFn KeyExists) uint32 {
 	return nextRandomNumber()
 }
 
-// CreateHarvestedResourceKeys returns a new resource keys object
+// CreateHarvestedResourceKeys returns a new resource keys object, built only from
+// the content that was captured while harvesting (no additional HTTP requests)
 func CreateHarvestedResourceKeys(hr *HarvestedResource, existsFn KeyExists) *HarvestedResourceKeys {
+	return CreateHarvestedResourceKeysWithOptions(hr, existsFn, HarvestedResourceKeysOptions{})
+}
+
+// CreateHarvestedResourceKeysWithOptions returns a new resource keys object using the given options
+func CreateHarvestedResourceKeysWithOptions(hr *HarvestedResource, existsFn KeyExists, options HarvestedResourceKeysOptions) *HarvestedResourceKeys {
 	result := new(HarvestedResourceKeys)
 	result.hr = hr
-	result.uniqueID = generateUniqueID(existsFn)
-	// TODO this does an extra HTTP get, instead we should re-use a downloaded HTML
-	if hr.finalURL != nil {
-		result.pageInfo, result.piError = og.GetPageInfoFromUrl(hr.finalURL.String())
-	} else {
-		result.pageInfo = nil
-		result.piError = fmt.Errorf("HR %s finalURL is null", hr.OriginalURLText())
+	idStrategy := options.IDStrategy
+	if idStrategy == nil {
+		idStrategy = RandomResourceIDStrategy{}
+	}
+	result.uniqueID, result.uniqueKey = idStrategy.GenerateResourceID(hr, existsFn)
+
+	if content := hr.ResourceContent(); content != nil {
+		result.title = firstMetaValue(content, "og:title", "twitter:title")
+		if len(result.title) == 0 {
+			result.title, _ = content.GetTitleElement()
+		}
+		result.description = firstMetaValue(content, "og:description", "twitter:description", "description")
+		if document, _ := content.PDF(); document != nil {
+			if len(result.title) == 0 {
+				result.title = document.DisplayTitle()
+			}
+			if len(result.description) == 0 {
+				result.description = document.Subject
+			}
+		}
+		for _, image := range content.OpenGraphImages() {
+			if len(image.Value) > 0 && !containsString(result.images, image.Value) {
+				result.images = append(result.images, image.Value)
+			}
+		}
+		for _, key := range []string{"twitter:image", "twitter:image:src"} {
+			for _, image := range content.GetMetaTagValues(key) {
+				if len(image) > 0 && !containsString(result.images, image) {
+					result.images = append(result.images, image)
+				}
+			}
+		}
 	}
 
+	result.piError = result.resolveTitle(hr, options)
+
+	slugStrategy := options.SlugStrategy
+	if slugStrategy == nil {
+		slugStrategy = MakeDefaultSlugStrategy()
+	}
+	result.slug = slugStrategy.GenerateSlug(result)
 	return result
 }
 
+// resolveTitle uses the network fallback, if allowed, when the harvested content didn't have a title
+func (keys *HarvestedResourceKeys) resolveTitle(hr *HarvestedResource, options HarvestedResourceKeysOptions) error {
+	if len(keys.title) > 0 {
+		return nil
+	}
+
+	if hr.finalURL == nil {
+		return fmt.Errorf("HR %s finalURL is null", hr.OriginalURLText())
+	}
+
+	if !options.AllowNetworkFallback {
+		return nil
+	}
+
+	pageInfo, err := og.GetPageInfoFromUrl(hr.finalURL.String())
+	if err != nil {
+		return err
+	}
+
+	keys.pageInfo = pageInfo
+	keys.title = pageInfo.Title
+	if len(keys.description) == 0 {
+		keys.description = pageInfo.Description
+	}
+	for _, image := range pageInfo.Images {
+		if image != nil && len(image.Url) > 0 && !containsString(keys.images, image.Url) {
+			keys.images = append(keys.images, image.Url)
+		}
+	}
+	return nil
+}
+
+// firstMetaValue returns the first non-empty meta tag value found in the given keys order
+func firstMetaValue(content *HarvestedResourceContent, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := content.GetMetaTag(key); ok && len(strings.TrimSpace(value)) > 0 {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
 // Random number state, approach copied from tempfile.go standard library
 var rand uint32
 var randmu sync.Mutex
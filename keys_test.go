@@ -0,0 +1,161 @@
+package harvester
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// keysTestFixtures are the pages KeysSuite creates keys for, besides commonTestPages
+var keysTestFixtures = testFixtures{pages: map[string]string{
+	"/articles/a-path-based-slug.html": `<html><head></head><body></body></html>`,
+	"/cyrillic":                        `<html><head><title>Привет мир</title></head><body></body></html>`,
+}}
+
+type KeysSuite struct {
+	harvesterSuite
+}
+
+func (suite *KeysSuite) SetupSuite() {
+	suite.setupSuite(keysTestFixtures.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+}
+
+func (suite *KeysSuite) harvest(path string) *HarvestedResource {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s%s in a mock tweet", suite.server.URL, path), suite.span)
+	suite.Equal(1, len(harvested.Resources))
+	return harvested.Resources[0]
+}
+
+func (suite *KeysSuite) TestKeysFromOpenGraph() {
+	keys := CreateHarvestedResourceKeys(suite.harvest("/og"), func(random uint32, try int) bool { return false })
+	suite.True(keys.IsValid())
+	suite.Equal("Open Graph Title", keys.Title())
+	suite.Equal("Open Graph Description", keys.Description())
+	suite.Equal([]string{"https://example.com/og.png", "https://example.com/twitter.png"}, keys.Images())
+	suite.Equal("open-graph-title", keys.Slug())
+}
+
+func (suite *KeysSuite) TestKeysFromTitleElement() {
+	keys := CreateHarvestedResourceKeys(suite.harvest("/title-only"), func(random uint32, try int) bool { return false })
+	suite.True(keys.IsValid())
+	suite.Equal("Only the Title Element", keys.Title())
+	suite.Equal("only-the-title-element", keys.Slug())
+}
+
+func (suite *KeysSuite) TestKeysWithoutTitleStayOffline() {
+	keys := CreateHarvestedResourceKeys(suite.harvest("/untitled"), func(random uint32, try int) bool { return false })
+	suite.True(keys.IsValid(), "A missing title should not make the keys invalid")
+	suite.False(keys.HasTitle(), "Keys should not have a title without the network fallback")
+	suite.Empty(keys.Title())
+	suite.Nil(keys.pageInfo, "The network fallback should not be used unless allowed")
+	suite.Equal("untitled", keys.Slug(), "Slug should fall back to the URL path")
+}
+
+func (suite *KeysSuite) TestSlugFallbacks() {
+	keys := CreateHarvestedResourceKeys(suite.harvest("/articles/a-path-based-slug.html"), func(random uint32, try int) bool { return false })
+	suite.Equal("a-path-based-slug", keys.Slug())
+
+	keys = CreateHarvestedResourceKeys(suite.harvest("/cyrillic"), func(random uint32, try int) bool { return false })
+	suite.Equal("privet-mir", keys.Slug(), "Non-Latin titles should be transliterated")
+
+	hr := suite.harvest("/untitled")
+	hr.finalURL, _ = url.Parse(suite.server.URL + "/")
+	keys = CreateHarvestedResourceKeys(hr, func(random uint32, try int) bool { return false })
+	suite.Equal("127-0-0-1-"+keys.UniqueKey(), keys.Slug(), "Slug should fall back to the hostname and ID")
+}
+
+func (suite *KeysSuite) TestSlugTruncationAndUniqueness() {
+	hr := suite.harvest("/og")
+	keys := CreateHarvestedResourceKeysWithOptions(hr, func(random uint32, try int) bool { return false },
+		HarvestedResourceKeysOptions{SlugStrategy: MakeFallbackSlugStrategy(14, nil)})
+	suite.Equal("open-graph", keys.Slug(), "Slug should be truncated on a word boundary")
+
+	existing := map[string]bool{"open-graph-title": true, "open-graph-title-2": true}
+	keys = CreateHarvestedResourceKeysWithOptions(hr, func(random uint32, try int) bool { return false },
+		HarvestedResourceKeysOptions{SlugStrategy: MakeFallbackSlugStrategy(0, func(slug string, try int) bool {
+			return existing[slug]
+		})})
+	suite.Equal("open-graph-title-3", keys.Slug())
+}
+
+func (suite *KeysSuite) TestContentAddressedIDsAreDeterministic() {
+	hr := suite.harvest("/og")
+	options := HarvestedResourceKeysOptions{IDStrategy: MakeDefaultContentAddressedResourceIDStrategy()}
+	first := CreateHarvestedResourceKeysWithOptions(hr, func(random uint32, try int) bool { return false }, options)
+	second := CreateHarvestedResourceKeysWithOptions(suite.harvest("/og"), func(random uint32, try int) bool { return false }, options)
+	suite.Equal(first.UniqueID(), second.UniqueID())
+	suite.Equal(first.UniqueKey(), second.UniqueKey())
+	suite.Len(first.UniqueKey(), 12)
+	suite.Regexp("^[a-z2-7]+$", first.UniqueKey())
+
+	strategy, err := MakeContentAddressedResourceIDStrategy(8, Base62Alphabet)
+	suite.Require().NoError(err)
+	base62 := CreateHarvestedResourceKeysWithOptions(hr, func(random uint32, try int) bool { return false },
+		HarvestedResourceKeysOptions{IDStrategy: strategy})
+	suite.Len(base62.UniqueKey(), 8)
+	suite.Regexp("^[0-9A-Za-z]+$", base62.UniqueKey())
+}
+
+func (suite *KeysSuite) TestContentAddressedIDStrategyValidation() {
+	for _, invalid := range []struct {
+		length   int
+		alphabet string
+	}{{12, ""}, {12, "a"}, {12, "abca"}, {12, "abcé"}, {0, Base32Alphabet}, {-1, Base32Alphabet}, {53, Base32Alphabet}, {257, "01"}} {
+		strategy, err := MakeContentAddressedResourceIDStrategy(invalid.length, invalid.alphabet)
+		suite.Error(err, "length %d, alphabet %q", invalid.length, invalid.alphabet)
+		suite.Nil(strategy)
+	}
+
+	// the longest IDs use every digit of the digest, padded when it has leading zeros
+	strategy, err := MakeContentAddressedResourceIDStrategy(52, Base32Alphabet)
+	suite.Require().NoError(err)
+	for _, input := range []string{"a", "b", "c", "d"} {
+		_, text := strategy.hash(input)
+		suite.Len(text, 52)
+	}
+	binary, err := MakeContentAddressedResourceIDStrategy(256, "01")
+	suite.Require().NoError(err)
+	_, text := binary.hash("a")
+	suite.Len(text, 256)
+}
+
+func (suite *KeysSuite) TestContentAddressedIDCollisions() {
+	hr := suite.harvest("/og")
+	options := HarvestedResourceKeysOptions{IDStrategy: MakeDefaultContentAddressedResourceIDStrategy()}
+	original := CreateHarvestedResourceKeysWithOptions(hr, func(random uint32, try int) bool { return false }, options)
+	var lastTry int
+	collided := CreateHarvestedResourceKeysWithOptions(hr, func(id uint32, try int) bool {
+		lastTry = try
+		return id == original.UniqueID()
+	}, options)
+	suite.Equal(1, lastTry)
+	suite.NotEqual(original.UniqueID(), collided.UniqueID())
+	suite.NotEqual(original.UniqueKey(), collided.UniqueKey())
+
+	// with only four possible text IDs most inputs collide, and KeyExists must see every collision
+	strategy, err := MakeContentAddressedResourceIDStrategy(2, "01")
+	suite.Require().NoError(err)
+	idsByText := make(map[string]uint32)
+	for i := 0; i < 32; i++ {
+		id, text := strategy.hash(fmt.Sprintf("input %d", i))
+		if seen, found := idsByText[text]; found {
+			suite.Equal(seen, id, "Inputs with the same text ID %q should have the same numeric ID", text)
+		}
+		idsByText[text] = id
+	}
+	suite.Len(idsByText, 4)
+}
+
+func (suite *KeysSuite) TestCanonicalURL() {
+	u, _ := url.Parse("HTTPS://Example.COM:443?b=2&a=1#section")
+	suite.Equal("https://example.com/?a=1&b=2", CanonicalURL(u))
+	u, _ = url.Parse("http://example.com:8080/path")
+	suite.Equal("http://example.com:8080/path", CanonicalURL(u))
+}
+
+func TestKeysSuite(t *testing.T) {
+	suite.Run(t, new(KeysSuite))
+}
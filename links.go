@@ -0,0 +1,254 @@
+package harvester
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PageLink is a <link> element from the <head> of an HTML page
+type PageLink struct {
+	Rel      []string `json:"rel"`                // the lower-cased tokens of the rel attribute, e.g. [shortcut icon]
+	Href     string   `json:"href"`               // absolute URL, resolved against the page URL (or <base href>)
+	Type     string   `json:"type,omitempty"`     // media type, e.g. application/rss+xml
+	Title    string   `json:"title,omitempty"`    // e.g. the name of a feed
+	HrefLang string   `json:"hreflang,omitempty"` // language of an alternate version of the page
+	Sizes    string   `json:"sizes,omitempty"`    // icon sizes, e.g. 32x32 or any
+	Media    string   `json:"media,omitempty"`
+}
+
+// HasRel returns true if the link has the given relation (case-insensitive)
+func (l *PageLink) HasRel(rel string) bool {
+	for _, r := range l.Rel {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFeed returns true if the link is an RSS, Atom or JSON feed alternate
+func (l *PageLink) IsFeed() bool {
+	if !l.HasRel("alternate") {
+		return false
+	}
+	switch strings.ToLower(l.Type) {
+	case "application/rss+xml", "application/atom+xml", "application/feed+json", "application/json+feed":
+		return true
+	}
+	return false
+}
+
+// IsOEmbed returns true if the link is an oEmbed discovery endpoint
+func (l *PageLink) IsOEmbed() bool {
+	switch strings.ToLower(l.Type) {
+	case "application/json+oembed", "text/xml+oembed", "application/xml+oembed":
+		return l.HasRel("alternate")
+	}
+	return false
+}
+
+// IconSize returns the largest width and height listed in the link's sizes, or false if there are none
+// (sizes="any", used by scalable icons, has no dimensions)
+func (l *PageLink) IconSize() (int, int, bool) {
+	var width, height int
+	for _, size := range strings.Fields(strings.ToLower(l.Sizes)) {
+		parts := strings.Split(size, "x")
+		if len(parts) != 2 {
+			continue
+		}
+		w, wErr := strconv.Atoi(parts[0])
+		h, hErr := strconv.Atoi(parts[1])
+		if wErr == nil && hErr == nil && w*h > width*height {
+			width, height = w, h
+		}
+	}
+	return width, height, width > 0
+}
+
+// iconRels are the relations used for favicons and touch icons
+var iconRels = []string{"icon", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon", "fluid-icon"}
+
+// PageLinks are the <link> elements of an HTML page, in document order
+type PageLinks []*PageLink
+
+// WithRel returns the links with the given relation
+func (links PageLinks) WithRel(rel string) PageLinks {
+	var result PageLinks
+	for _, link := range links {
+		if link.HasRel(rel) {
+			result = append(result, link)
+		}
+	}
+	return result
+}
+
+// Icons returns the favicons and touch icons
+func (links PageLinks) Icons() PageLinks {
+	var result PageLinks
+	for _, link := range links {
+		for _, rel := range iconRels {
+			if link.HasRel(rel) {
+				result = append(result, link)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// LargestIcon returns the icon with the largest declared size, or the first icon if none declare a size
+func (links PageLinks) LargestIcon() *PageLink {
+	var result *PageLink
+	var largest int
+	for _, icon := range links.Icons() {
+		if result == nil {
+			result = icon
+		}
+		if width, height, ok := icon.IconSize(); ok && width*height > largest {
+			result, largest = icon, width*height
+		}
+	}
+	return result
+}
+
+// Feeds returns the RSS, Atom and JSON feed alternates
+func (links PageLinks) Feeds() PageLinks {
+	var result PageLinks
+	for _, link := range links {
+		if link.IsFeed() {
+			result = append(result, link)
+		}
+	}
+	return result
+}
+
+// LanguageAlternates returns the alternate versions of the page in other languages (hreflang)
+func (links PageLinks) LanguageAlternates() PageLinks {
+	var result PageLinks
+	for _, link := range links {
+		if link.HasRel("alternate") && len(link.HrefLang) > 0 {
+			result = append(result, link)
+		}
+	}
+	return result
+}
+
+// OEmbedEndpoints returns the oEmbed discovery endpoints
+func (links PageLinks) OEmbedEndpoints() PageLinks {
+	var result PageLinks
+	for _, link := range links {
+		if link.IsOEmbed() {
+			result = append(result, link)
+		}
+	}
+	return result
+}
+
+// Canonical returns the canonical URL of the page, or nil if it doesn't declare one
+func (links PageLinks) Canonical() *PageLink {
+	return links.first("canonical")
+}
+
+// AMP returns the URL of the AMP version of the page, or nil if there isn't one
+func (links PageLinks) AMP() *PageLink {
+	return links.first("amphtml")
+}
+
+// ShortLink returns the short URL of the page, or nil if it doesn't declare one
+func (links PageLinks) ShortLink() *PageLink {
+	return links.first("shortlink")
+}
+
+// Manifest returns the web app manifest of the page, or nil if there isn't one
+func (links PageLinks) Manifest() *PageLink {
+	return links.first("manifest")
+}
+
+func (links PageLinks) first(rel string) *PageLink {
+	if matched := links.WithRel(rel); len(matched) > 0 {
+		return matched[0]
+	}
+	return nil
+}
+
+// parsePageLinks collects the <link> elements in the <head> of doc, resolving their URLs against
+// <base href> if there is one or else against pageURL
+func parsePageLinks(doc *html.Node, pageURL *url.URL) PageLinks {
+	head := findElement(doc, "head")
+	if head == nil {
+		return nil
+	}
+
+	base := pageURL
+	if baseHref, found := firstAttribute(head, "base", "href"); found && pageURL != nil {
+		if baseURL, err := pageURL.Parse(baseHref); err == nil {
+			base = baseURL
+		}
+	}
+
+	var result PageLinks
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "link") {
+			if link := makePageLink(n, base); link != nil {
+				result = append(result, link)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(head)
+	return result
+}
+
+func makePageLink(n *html.Node, base *url.URL) *PageLink {
+	link := new(PageLink)
+	for _, attr := range n.Attr {
+		value := strings.TrimSpace(attr.Val)
+		switch strings.ToLower(attr.Key) {
+		case "rel":
+			link.Rel = strings.Fields(strings.ToLower(value))
+		case "href":
+			link.Href = value
+		case "type":
+			link.Type = value
+		case "title":
+			link.Title = value
+		case "hreflang":
+			link.HrefLang = value
+		case "sizes":
+			link.Sizes = value
+		case "media":
+			link.Media = value
+		}
+	}
+	if len(link.Rel) == 0 || len(link.Href) == 0 {
+		return nil
+	}
+	if base != nil {
+		if resolved, err := base.Parse(link.Href); err == nil {
+			link.Href = resolved.String()
+		}
+	}
+	return link
+}
+
+// findElement returns the first element with the given name
+func findElement(n *html.Node, element string) *html.Node {
+	if n == nil {
+		return nil
+	}
+	if n.Type == html.ElementNode && strings.EqualFold(n.Data, element) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, element); found != nil {
+			return found
+		}
+	}
+	return nil
+}
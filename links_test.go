@@ -0,0 +1,95 @@
+package harvester
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var linksTestFixtures = testFixtures{pages: map[string]string{
+	"/links": `<html><head><title>Links</title>
+		<base href="/blog/" />
+		<link rel="shortcut icon" href="/favicon.ico" />
+		<link rel="icon" type="image/png" sizes="16x16 32x32" href="icon-32.png" />
+		<link rel="apple-touch-icon" sizes="180x180" href="https://cdn.example.com/touch.png" />
+		<link rel="alternate" type="application/rss+xml" title="Example RSS" href="feed.xml" />
+		<link rel="alternate" type="application/atom+xml" title="Example Atom" href="atom.xml" />
+		<link rel="alternate" hreflang="fr" href="https://example.fr/liens" />
+		<link rel="alternate" type="application/json+oembed" href="/oembed?format=json" />
+		<link rel="canonical" href="https://example.com/links" />
+		<link rel="amphtml" href="links.amp" />
+		<link rel="shortlink" href="https://ex.co/l" />
+		<link rel="manifest" href="/manifest.json" />
+		<link rel="stylesheet" />
+		</head><body></body></html>`,
+}}
+
+type LinksSuite struct {
+	harvesterSuite
+	hr *HarvestedResource
+}
+
+func (suite *LinksSuite) SetupSuite() {
+	suite.setupSuite(linksTestFixtures.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s/links in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	suite.hr = harvested.Resources[0]
+}
+
+func (suite *LinksSuite) TestLinks() {
+	links := suite.hr.ResourceContent().Links()
+	suite.Equal(11, len(links), "Links without an href should be skipped")
+
+	icons := links.Icons()
+	suite.Equal(3, len(icons))
+	suite.Equal(suite.server.URL+"/favicon.ico", icons[0].Href)
+	suite.True(icons[0].HasRel("icon"))
+	suite.Equal(suite.server.URL+"/blog/icon-32.png", icons[1].Href, "Relative URLs should be resolved against <base href>")
+	width, height, ok := icons[1].IconSize()
+	suite.True(ok)
+	suite.Equal(32, width)
+	suite.Equal(32, height)
+	suite.Equal("https://cdn.example.com/touch.png", links.LargestIcon().Href)
+
+	feeds := links.Feeds()
+	suite.Equal(2, len(feeds))
+	suite.Equal("Example RSS", feeds[0].Title)
+	suite.Equal(suite.server.URL+"/blog/feed.xml", feeds[0].Href)
+	suite.Equal("application/atom+xml", feeds[1].Type)
+
+	alternates := links.LanguageAlternates()
+	suite.Equal(1, len(alternates))
+	suite.Equal("fr", alternates[0].HrefLang)
+
+	endpoints := links.OEmbedEndpoints()
+	suite.Equal(1, len(endpoints))
+	suite.Equal(suite.server.URL+"/oembed?format=json", endpoints[0].Href)
+	suite.Equal("https://example.com/links", links.Canonical().Href)
+	suite.Equal(suite.server.URL+"/blog/links.amp", links.AMP().Href)
+	suite.Equal("https://ex.co/l", links.ShortLink().Href)
+	suite.Equal(suite.server.URL+"/manifest.json", links.Manifest().Href)
+}
+
+func (suite *LinksSuite) TestNoLinks() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s/title-only in a mock tweet", suite.server.URL), suite.span)
+	links := harvested.Resources[0].ResourceContent().Links()
+	suite.Nil(links.Canonical())
+	suite.Nil(links.LargestIcon())
+	suite.Equal(0, len(links.Feeds()))
+}
+
+func (suite *LinksSuite) TestJSONRoundTrip() {
+	data, err := json.Marshal(suite.hr)
+	suite.Require().NoError(err)
+
+	decoded := new(HarvestedResource)
+	suite.Require().NoError(json.Unmarshal(data, decoded))
+	suite.Equal(suite.hr.ResourceContent().Links(), decoded.ResourceContent().Links())
+}
+
+func TestLinksSuite(t *testing.T) {
+	suite.Run(t, new(LinksSuite))
+}
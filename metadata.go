@@ -0,0 +1,226 @@
+package harvester
+
+import (
+	"strings"
+	"time"
+)
+
+// MetadataSource is where a resolved metadata value came from
+type MetadataSource string
+
+// The sources metadata is resolved from
+const (
+	// MetadataStructuredData is schema.org JSON-LD, microdata or RDFa (see StructuredData)
+	MetadataStructuredData MetadataSource = "structured-data"
+
+	// MetadataOpenGraph is <meta property="og:*"> and <meta property="article:*">
+	MetadataOpenGraph MetadataSource = "open-graph"
+
+	// MetadataTwitter is <meta name="twitter:*">
+	MetadataTwitter MetadataSource = "twitter"
+
+	// MetadataDublinCore is <meta name="DC.*"> and <meta name="dcterms.*">
+	MetadataDublinCore MetadataSource = "dublin-core"
+
+	// MetadataStandard is standard meta tags such as <meta name="description">
+	MetadataStandard MetadataSource = "standard"
+
+	// MetadataHTML is HTML elements such as <title> and <html lang>
+	MetadataHTML MetadataSource = "html"
+)
+
+// DefaultMetadataPrecedence is the order in which sources are consulted when resolving metadata:
+// publishers' structured data is the most authoritative, followed by the social network tags and
+// finally the generic meta tags and HTML elements
+var DefaultMetadataPrecedence = []MetadataSource{MetadataStructuredData, MetadataOpenGraph, MetadataTwitter,
+	MetadataDublinCore, MetadataStandard, MetadataHTML}
+
+// MetadataValue is a resolved metadata value along with the source it was resolved from
+type MetadataValue struct {
+	Value  string
+	Source MetadataSource // empty if no source had a value
+}
+
+// IsSet returns true if a value was found
+func (v MetadataValue) IsSet() bool {
+	return len(v.Value) > 0
+}
+
+// Time parses the value as a date and time, returning false if it's not set or can't be parsed
+func (v MetadataValue) Time() (time.Time, bool) {
+	return parseArticleDate(v.Value)
+}
+
+// List splits a comma separated value (e.g. keywords) into its trimmed, non-empty, elements
+func (v MetadataValue) List() []string {
+	var result []string
+	for _, element := range strings.Split(v.Value, ",") {
+		if element = strings.TrimSpace(element); len(element) > 0 {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+// Metadata is a unified view of a page's metadata, each value resolved from the first source (in
+// order of precedence) that has it
+type Metadata struct {
+	Title         MetadataValue
+	Description   MetadataValue
+	Image         MetadataValue
+	SiteName      MetadataValue
+	Author        MetadataValue
+	PublishedTime MetadataValue
+	ModifiedTime  MetadataValue
+	Locale        MetadataValue
+	Type          MetadataValue
+	Keywords      MetadataValue
+}
+
+// metadataCandidates are the values a single source has for each field of Metadata
+type metadataCandidates struct {
+	title, description, image, siteName, author, published, modified, locale, kind, keywords string
+}
+
+// Metadata resolves the content's metadata using DefaultMetadataPrecedence
+func (c HarvestedResourceContent) Metadata() *Metadata {
+	return c.MetadataWithPrecedence(DefaultMetadataPrecedence)
+}
+
+// MetadataWithPrecedence resolves the content's metadata consulting the sources in the given order;
+// sources which aren't listed are not consulted
+func (c HarvestedResourceContent) MetadataWithPrecedence(precedence []MetadataSource) *Metadata {
+	result := new(Metadata)
+	for _, source := range precedence {
+		candidates := c.metadataCandidates(source)
+		resolveMetadataValue(&result.Title, candidates.title, source)
+		resolveMetadataValue(&result.Description, candidates.description, source)
+		resolveMetadataValue(&result.Image, candidates.image, source)
+		resolveMetadataValue(&result.SiteName, candidates.siteName, source)
+		resolveMetadataValue(&result.Author, candidates.author, source)
+		resolveMetadataValue(&result.PublishedTime, candidates.published, source)
+		resolveMetadataValue(&result.ModifiedTime, candidates.modified, source)
+		resolveMetadataValue(&result.Locale, candidates.locale, source)
+		resolveMetadataValue(&result.Type, candidates.kind, source)
+		resolveMetadataValue(&result.Keywords, candidates.keywords, source)
+	}
+	return result
+}
+
+func resolveMetadataValue(value *MetadataValue, candidate string, source MetadataSource) {
+	if value.IsSet() {
+		return
+	}
+	if candidate = strings.TrimSpace(candidate); len(candidate) > 0 {
+		value.Value = candidate
+		value.Source = source
+	}
+}
+
+func (c HarvestedResourceContent) metadataCandidates(source MetadataSource) metadataCandidates {
+	meta := func(names ...string) string {
+		return c.metaTagFold(names...)
+	}
+
+	var result metadataCandidates
+	switch source {
+	case MetadataStructuredData:
+		item := c.primaryStructuredDataItem()
+		if item == nil {
+			break
+		}
+		result.title = item.Text("headline")
+		if len(result.title) == 0 {
+			result.title = item.Text("name")
+		}
+		result.description = item.Text("description")
+		result.image = item.Text("image")
+		result.siteName = item.Text("publisher")
+		result.author = strings.Join(item.Texts("author"), ", ")
+		result.published = item.Text("datePublished")
+		result.modified = item.Text("dateModified")
+		result.locale = item.Text("inLanguage")
+		if len(item.Types) > 0 {
+			result.kind = item.Types[0]
+		}
+		result.keywords = strings.Join(item.Texts("keywords"), ", ")
+	case MetadataOpenGraph:
+		result.title = meta("og:title")
+		result.description = meta("og:description")
+		result.image = meta("og:image", "og:image:url", "og:image:secure_url")
+		result.siteName = meta("og:site_name")
+		result.author = meta("article:author", "book:author")
+		result.published = meta("article:published_time", "og:published_time")
+		result.modified = meta("article:modified_time", "og:updated_time")
+		result.locale = meta("og:locale")
+		result.kind = meta("og:type")
+		result.keywords = strings.Join(c.GetMetaTagValues("article:tag"), ", ")
+	case MetadataTwitter:
+		result.title = meta("twitter:title")
+		result.description = meta("twitter:description")
+		result.image = meta("twitter:image", "twitter:image:src")
+		result.siteName = meta("twitter:site")
+		result.author = meta("twitter:creator")
+	case MetadataDublinCore:
+		result.title = meta("DC.title", "dcterms.title")
+		result.description = meta("DC.description", "dcterms.description", "dcterms.abstract")
+		result.siteName = meta("DC.publisher", "dcterms.publisher")
+		result.author = meta("DC.creator", "dcterms.creator")
+		result.published = meta("DC.date.issued", "dcterms.issued", "DC.date", "dcterms.date", "dcterms.created")
+		result.modified = meta("DC.date.modified", "dcterms.modified")
+		result.locale = meta("DC.language", "dcterms.language")
+		result.kind = meta("DC.type", "dcterms.type")
+		result.keywords = meta("DC.subject", "dcterms.subject")
+	case MetadataStandard:
+		result.description = meta("description")
+		result.siteName = meta("application-name")
+		result.author = meta("author")
+		result.published = meta("date", "pubdate", "publish-date", "publish_date")
+		result.modified = meta("last-modified", "revised")
+		result.locale = meta("language", "content-language")
+		result.keywords = meta("keywords", "news_keywords")
+	case MetadataHTML:
+		result.title = c.titleElementText
+		result.locale = c.htmlLang
+	}
+	return result
+}
+
+// pageStructuredDataTypes are the schema.org types, besides articleTypes, which describe the page itself;
+// other items such as Organization, WebSite or BreadcrumbList describe something else and are ignored
+var pageStructuredDataTypes = []string{"CreativeWork", "WebPage", "AboutPage", "CollectionPage", "ItemPage",
+	"ProfilePage", "QAPage", "FAQPage", "MediaObject", "VideoObject", "AudioObject", "PodcastEpisode", "Book",
+	"Recipe", "Review", "Course", "Event", "Product"}
+
+// primaryStructuredDataItem returns the first article, or else the first item describing the page, of
+// the structured data
+func (c HarvestedResourceContent) primaryStructuredDataItem() *StructuredDataItem {
+	if c.structuredData == nil {
+		return nil
+	}
+	if article := c.structuredData.Article(); article != nil {
+		return article.Item
+	}
+	if items := c.structuredData.ItemsOfType(pageStructuredDataTypes...); len(items) > 0 {
+		return items[0]
+	}
+	return nil
+}
+
+// metaTagFold returns the first non-empty value of the meta tags with the given names, in document
+// order; a name which matches exactly is preferred to one which only matches when ignoring case
+func (c HarvestedResourceContent) metaTagFold(names ...string) string {
+	for _, name := range names {
+		for _, tag := range c.metaTags {
+			if tag.Name == name && len(strings.TrimSpace(tag.Value)) > 0 {
+				return tag.Value
+			}
+		}
+		for _, tag := range c.metaTags {
+			if strings.EqualFold(tag.Name, name) && len(strings.TrimSpace(tag.Value)) > 0 {
+				return tag.Value
+			}
+		}
+	}
+	return ""
+}
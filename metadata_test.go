@@ -0,0 +1,107 @@
+package harvester
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var metadataTestFixtures = testFixtures{pages: map[string]string{
+	"/metadata": `<html lang="en-GB"><head><title>Element Title</title>
+		<meta property="og:title" content="Open Graph Title" />
+		<meta property="og:site_name" content="Open Graph Site" />
+		<meta name="twitter:description" content="Twitter Description" />
+		<meta name="dc.creator" content="Dublin Core Creator" />
+		<meta name="DC.date.modified" content="2019-06-01" />
+		<meta name="keywords" content="harvesting, links , ,metadata" />
+		<script type="application/ld+json">
+		{"@context": "https://schema.org", "@type": "NewsArticle", "headline": "Structured Headline",
+		 "datePublished": "2019-05-01T10:00:00Z"}
+		</script>
+		</head><body></body></html>`,
+	"/metadata-organization": `<html><head><title>Element Title</title>
+		<meta name="AUTHOR" content="First Author" />
+		<meta name="Author" content="Second Author" />
+		<meta name="DC.Creator" content="First Creator" />
+		<meta name="dc.CREATOR" content="Second Creator" />
+		<script type="application/ld+json">
+		[{"@context": "https://schema.org", "@type": "Organization", "name": "Example Inc.", "description": "We make examples"},
+		 {"@context": "https://schema.org", "@type": "BreadcrumbList", "name": "Home"}]
+		</script>
+		</head><body></body></html>`,
+}}
+
+type MetadataSuite struct {
+	harvesterSuite
+}
+
+func (suite *MetadataSuite) SetupSuite() {
+	suite.setupSuite(metadataTestFixtures.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+}
+
+func (suite *MetadataSuite) content(path string) *HarvestedResourceContent {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s%s in a mock tweet", suite.server.URL, path), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return harvested.Resources[0].ResourceContent()
+}
+
+func (suite *MetadataSuite) TestDefaultPrecedence() {
+	metadata := suite.content("/metadata").Metadata()
+	suite.Equal(MetadataValue{Value: "Structured Headline", Source: MetadataStructuredData}, metadata.Title)
+	suite.Equal(MetadataValue{Value: "Twitter Description", Source: MetadataTwitter}, metadata.Description)
+	suite.Equal(MetadataValue{Value: "Open Graph Site", Source: MetadataOpenGraph}, metadata.SiteName)
+	suite.Equal(MetadataValue{Value: "Dublin Core Creator", Source: MetadataDublinCore}, metadata.Author, "Meta tag names should be case insensitive")
+	suite.Equal(MetadataValue{Value: "NewsArticle", Source: MetadataStructuredData}, metadata.Type)
+	suite.Equal(MetadataValue{Value: "en-GB", Source: MetadataHTML}, metadata.Locale)
+	suite.Equal(MetadataStandard, metadata.Keywords.Source)
+	suite.Equal([]string{"harvesting", "links", "metadata"}, metadata.Keywords.List())
+	suite.False(metadata.Image.IsSet())
+	suite.Equal(MetadataSource(""), metadata.Image.Source)
+
+	published, ok := metadata.PublishedTime.Time()
+	suite.True(ok)
+	suite.True(published.Equal(time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)))
+	modified, ok := metadata.ModifiedTime.Time()
+	suite.True(ok)
+	suite.True(modified.Equal(time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func (suite *MetadataSuite) TestCustomPrecedence() {
+	content := suite.content("/metadata")
+	metadata := content.MetadataWithPrecedence([]MetadataSource{MetadataHTML, MetadataOpenGraph})
+	suite.Equal(MetadataValue{Value: "Element Title", Source: MetadataHTML}, metadata.Title)
+	suite.False(metadata.Author.IsSet(), "Sources which aren't listed should not be consulted")
+
+	metadata = content.MetadataWithPrecedence([]MetadataSource{MetadataOpenGraph, MetadataStructuredData})
+	suite.Equal(MetadataValue{Value: "Open Graph Title", Source: MetadataOpenGraph}, metadata.Title)
+}
+
+func (suite *MetadataSuite) TestSocialTagsWithoutStructuredData() {
+	metadata := suite.content("/og").Metadata()
+	suite.Equal(MetadataValue{Value: "Open Graph Title", Source: MetadataOpenGraph}, metadata.Title)
+	suite.Equal(MetadataValue{Value: "https://example.com/og.png", Source: MetadataOpenGraph}, metadata.Image)
+}
+
+func (suite *MetadataSuite) TestStructuredDataAboutSomethingElse() {
+	content := suite.content("/metadata-organization")
+	suite.Require().NotNil(content.StructuredData())
+	metadata := content.Metadata()
+	suite.Equal(MetadataValue{Value: "Element Title", Source: MetadataHTML}, metadata.Title, "An Organization doesn't describe the page")
+	suite.False(metadata.Description.IsSet())
+	suite.False(metadata.Type.IsSet())
+}
+
+func (suite *MetadataSuite) TestCaseInsensitiveNamesInDocumentOrder() {
+	content := suite.content("/metadata-organization")
+	for i := 0; i < 10; i++ {
+		suite.Equal("First Author", content.metaTagFold("author"))
+		suite.Equal("First Creator", content.metaTagFold("DC.creator", "dcterms.creator"))
+	}
+}
+
+func TestMetadataSuite(t *testing.T) {
+	suite.Run(t, new(MetadataSuite))
+}
@@ -0,0 +1,105 @@
+package harvester
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MetaTag is a <meta> element with a property or name attribute, like <meta property="og:image" content="...">
+type MetaTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// OpenGraphObject is an OpenGraph property grouped with its structured properties, e.g. an og:image along
+// with the og:image:width, og:image:height and og:image:alt tags which follow it
+type OpenGraphObject struct {
+	Property   string            // the root property, e.g. og:image
+	Value      string            // the content of the root property (or of its :url structured property)
+	Properties map[string]string // the structured properties without the root prefix, e.g. width or alt
+}
+
+// Get returns the value of a structured property, e.g. "alt"
+func (o *OpenGraphObject) Get(name string) string {
+	return o.Properties[name]
+}
+
+// Int returns the value of a numeric structured property, e.g. "width", or 0 if it's missing or not a number
+func (o *OpenGraphObject) Int(name string) int {
+	result, _ := strconv.Atoi(strings.TrimSpace(o.Properties[name]))
+	return result
+}
+
+// addMetaTag records a meta tag; the first value of each name is also indexed for single-valued lookups
+func (c *HarvestedResourceContent) addMetaTag(name, value string) {
+	c.metaTags = append(c.metaTags, MetaTag{Name: name, Value: value})
+	if _, found := c.metaPropertyTags[name]; !found {
+		c.metaPropertyTags[name] = value
+	}
+}
+
+// OrderedMetaTags returns a copy of all the meta tags found in the content, in document order and
+// including every value of names which appear more than once
+func (c HarvestedResourceContent) OrderedMetaTags() []MetaTag {
+	return append([]MetaTag(nil), c.metaTags...)
+}
+
+// GetMetaTagValues returns the values of every meta tag with the given property or name, in document order
+func (c HarvestedResourceContent) GetMetaTagValues(key string) []string {
+	var result []string
+	for _, tag := range c.metaTags {
+		if tag.Name == key {
+			result = append(result, tag.Value)
+		}
+	}
+	return result
+}
+
+// GetOpenGraphMetaTagValues returns the values of every og:key meta tag, e.g. all og:locale:alternate values
+func (c HarvestedResourceContent) GetOpenGraphMetaTagValues(key string) []string {
+	return c.GetMetaTagValues("og:" + key)
+}
+
+// OpenGraphObjects groups each occurrence of an OpenGraph property (e.g. og:image) with the structured
+// properties (e.g. og:image:width) that follow it, as described at https://ogp.me/#structured
+func (c HarvestedResourceContent) OpenGraphObjects(property string) []*OpenGraphObject {
+	var result []*OpenGraphObject
+	var current *OpenGraphObject
+	prefix := property + ":"
+	for _, tag := range c.metaTags {
+		switch {
+		case tag.Name == property:
+			current = &OpenGraphObject{Property: property, Value: tag.Value, Properties: make(map[string]string)}
+			result = append(result, current)
+		case strings.HasPrefix(tag.Name, prefix):
+			name := strings.TrimPrefix(tag.Name, prefix)
+			if current == nil || (name == "url" && len(current.Properties["url"]) > 0) {
+				// a :url without a preceding root property (or a second one) starts a new object
+				current = &OpenGraphObject{Property: property, Properties: make(map[string]string)}
+				result = append(result, current)
+			}
+			if name == "url" && len(current.Value) == 0 {
+				current.Value = tag.Value
+			}
+			if _, found := current.Properties[name]; !found {
+				current.Properties[name] = tag.Value
+			}
+		}
+	}
+	return result
+}
+
+// OpenGraphImages returns every og:image with its structured properties
+func (c HarvestedResourceContent) OpenGraphImages() []*OpenGraphObject {
+	return c.OpenGraphObjects("og:image")
+}
+
+// OpenGraphVideos returns every og:video with its structured properties
+func (c HarvestedResourceContent) OpenGraphVideos() []*OpenGraphObject {
+	return c.OpenGraphObjects("og:video")
+}
+
+// OpenGraphAudio returns every og:audio with its structured properties
+func (c HarvestedResourceContent) OpenGraphAudio() []*OpenGraphObject {
+	return c.OpenGraphObjects("og:audio")
+}
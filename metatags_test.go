@@ -0,0 +1,117 @@
+package harvester
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var metaTagsTestFixtures = testFixtures{pages: map[string]string{
+	"/og-multi": `<html><head><title>Gallery</title>
+		<meta property="og:title" content="Gallery" />
+		<meta property="og:image" content="https://example.com/first.png" />
+		<meta property="og:image:width" content="1200" />
+		<meta property="og:image:height" content="630" />
+		<meta property="og:image:alt" content="The first image" />
+		<meta property="og:image" content="https://example.com/second.png" />
+		<meta property="og:image:secure_url" content="https://secure.example.com/second.png" />
+		<meta property="og:locale" content="en_US" />
+		<meta property="og:locale:alternate" content="fr_FR" />
+		<meta property="og:locale:alternate" content="de_DE" />
+		<meta property="article:tag" content="go" />
+		<meta property="article:tag" content="harvesting" />
+		<meta name="twitter:image" content="https://example.com/first.png" />
+		</head><body></body></html>`,
+	"/repeated": `<html><head><title>Repeated</title>
+		<meta property="og:title" content="First Title" />
+		<meta property="og:title" content="Second Title" />
+		<meta name="twitter:creator" content="@first" />
+		<meta name="twitter:creator" content="@second" />
+		</head><body></body></html>`,
+}}
+
+type MetaTagsSuite struct {
+	harvesterSuite
+	hr *HarvestedResource
+}
+
+func (suite *MetaTagsSuite) SetupSuite() {
+	suite.setupSuite(metaTagsTestFixtures.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s/og-multi in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	suite.hr = harvested.Resources[0]
+}
+
+func (suite *MetaTagsSuite) TestRepeatedValues() {
+	content := suite.hr.ResourceContent()
+	suite.Equal(13, len(content.OrderedMetaTags()))
+	suite.Equal([]string{"fr_FR", "de_DE"}, content.GetOpenGraphMetaTagValues("locale:alternate"))
+	suite.Equal([]string{"go", "harvesting"}, content.GetMetaTagValues("article:tag"))
+	suite.Nil(content.GetMetaTagValues("og:video"))
+
+	image, ok := content.GetOpenGraphMetaTag("image")
+	suite.True(ok)
+	suite.Equal("https://example.com/first.png", image, "Single-valued lookups should return the first value")
+	suite.Equal("https://example.com/first.png", content.MetaTags()["og:image"])
+	suite.Equal("go, harvesting", content.Metadata().Keywords.Value)
+}
+
+func (suite *MetaTagsSuite) TestFirstValueWins() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s/repeated in a mock tweet", suite.server.URL), suite.span)
+	content := harvested.Resources[0].ResourceContent()
+	title, _ := content.GetOpenGraphMetaTag("title")
+	suite.Equal("First Title", title)
+	creator, _ := content.GetTwitterMetaTag("creator")
+	suite.Equal("@first", creator)
+	suite.Equal([]string{"@first", "@second"}, content.GetMetaTagValues("twitter:creator"))
+}
+
+func (suite *MetaTagsSuite) TestOpenGraphStructuredProperties() {
+	images := suite.hr.ResourceContent().OpenGraphImages()
+	suite.Require().Equal(2, len(images))
+	suite.Equal("https://example.com/first.png", images[0].Value)
+	suite.Equal(1200, images[0].Int("width"))
+	suite.Equal(630, images[0].Int("height"))
+	suite.Equal("The first image", images[0].Get("alt"))
+	suite.Equal("https://example.com/second.png", images[1].Value)
+	suite.Equal("https://secure.example.com/second.png", images[1].Get("secure_url"))
+	suite.Equal(0, images[1].Int("width"), "Structured properties belong to the image they follow")
+
+	locales := suite.hr.ResourceContent().OpenGraphObjects("og:locale")
+	suite.Require().Equal(1, len(locales))
+	suite.Equal("en_US", locales[0].Value)
+	suite.Equal(0, len(suite.hr.ResourceContent().OpenGraphVideos()))
+}
+
+func (suite *MetaTagsSuite) TestUrlWithoutRootProperty() {
+	content := &HarvestedResourceContent{metaPropertyTags: make(map[string]string)}
+	content.addMetaTag("og:image:url", "https://example.com/a.png")
+	content.addMetaTag("og:image:width", "100")
+	content.addMetaTag("og:image:url", "https://example.com/b.png")
+	images := content.OpenGraphImages()
+	suite.Require().Equal(2, len(images))
+	suite.Equal("https://example.com/a.png", images[0].Value)
+	suite.Equal(100, images[0].Int("width"))
+	suite.Equal("https://example.com/b.png", images[1].Value)
+}
+
+func (suite *MetaTagsSuite) TestKeysIncludeEveryImage() {
+	keys := CreateHarvestedResourceKeys(suite.hr, func(random uint32, try int) bool { return false })
+	suite.Equal([]string{"https://example.com/first.png", "https://example.com/second.png"}, keys.Images())
+}
+
+func (suite *MetaTagsSuite) TestJSONRoundTrip() {
+	data, err := json.Marshal(suite.hr)
+	suite.Require().NoError(err)
+	decoded := new(HarvestedResource)
+	suite.Require().NoError(json.Unmarshal(data, decoded))
+	suite.Equal(suite.hr.ResourceContent().OrderedMetaTags(), decoded.ResourceContent().OrderedMetaTags())
+	suite.Equal(suite.hr.ResourceContent().MetaTags(), decoded.ResourceContent().MetaTags())
+}
+
+func TestMetaTagsSuite(t *testing.T) {
+	suite.Run(t, new(MetaTagsSuite))
+}
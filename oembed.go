@@ -0,0 +1,418 @@
+package harvester
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lectio/observe"
+	opentracing "github.com/opentracing/opentracing-go"
+	opentrext "github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// OEmbed is an oEmbed (https://oembed.com) response describing how to embed a resource
+type OEmbed struct {
+	XMLName         xml.Name `json:"-" xml:"oembed"`
+	Type            string   `json:"type" xml:"type"` // photo, video, link or rich
+	Version         string   `json:"version,omitempty" xml:"version,omitempty"`
+	Title           string   `json:"title,omitempty" xml:"title,omitempty"`
+	AuthorName      string   `json:"author_name,omitempty" xml:"author_name,omitempty"`
+	AuthorURL       string   `json:"author_url,omitempty" xml:"author_url,omitempty"`
+	ProviderName    string   `json:"provider_name,omitempty" xml:"provider_name,omitempty"`
+	ProviderURL     string   `json:"provider_url,omitempty" xml:"provider_url,omitempty"`
+	CacheAge        int      `json:"cache_age,omitempty" xml:"cache_age,omitempty"` // seconds
+	ThumbnailURL    string   `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int      `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	ThumbnailHeight int      `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+	URL             string   `json:"url,omitempty" xml:"url,omitempty"`   // the image of photo responses
+	HTML            string   `json:"html,omitempty" xml:"html,omitempty"` // the embed HTML of video and rich responses
+	Width           int      `json:"width,omitempty" xml:"width,omitempty"`
+	Height          int      `json:"height,omitempty" xml:"height,omitempty"`
+}
+
+// UnmarshalJSON accepts numbers sent as strings (e.g. "640"), which some providers do
+func (e *OEmbed) UnmarshalJSON(data []byte) error {
+	type plainOEmbed OEmbed
+	doc := struct {
+		*plainOEmbed
+		CacheAge        interface{} `json:"cache_age"`
+		ThumbnailWidth  interface{} `json:"thumbnail_width"`
+		ThumbnailHeight interface{} `json:"thumbnail_height"`
+		Width           interface{} `json:"width"`
+		Height          interface{} `json:"height"`
+	}{plainOEmbed: (*plainOEmbed)(e)}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	e.CacheAge = oEmbedInt(doc.CacheAge)
+	e.ThumbnailWidth = oEmbedInt(doc.ThumbnailWidth)
+	e.ThumbnailHeight = oEmbedInt(doc.ThumbnailHeight)
+	e.Width = oEmbedInt(doc.Width)
+	e.Height = oEmbedInt(doc.Height)
+	return nil
+}
+
+func oEmbedInt(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		result, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(v), "px"))
+		return result
+	}
+	return 0
+}
+
+// OEmbedProvider is an entry of an oEmbed provider registry, in the format of https://oembed.com/providers.json
+type OEmbedProvider struct {
+	Name      string            `json:"provider_name"`
+	URL       string            `json:"provider_url"`
+	Endpoints []*OEmbedEndpoint `json:"endpoints"`
+}
+
+// OEmbedEndpoint is where a provider answers oEmbed requests for URLs matching its schemes
+type OEmbedEndpoint struct {
+	Schemes   []string `json:"schemes,omitempty"` // URL patterns with * wildcards
+	URL       string   `json:"url"`               // may contain a {format} placeholder
+	Discovery bool     `json:"discovery,omitempty"`
+}
+
+// DefaultOEmbedProviders is a small built-in registry of popular rich media providers
+var DefaultOEmbedProviders = []*OEmbedProvider{
+	{Name: "YouTube", URL: "https://www.youtube.com/", Endpoints: []*OEmbedEndpoint{{
+		Schemes: []string{"https://*.youtube.com/watch*", "https://*.youtube.com/v/*", "https://youtu.be/*", "https://*.youtube.com/shorts/*"},
+		URL:     "https://www.youtube.com/oembed", Discovery: true}}},
+	{Name: "Vimeo", URL: "https://vimeo.com/", Endpoints: []*OEmbedEndpoint{{
+		Schemes: []string{"https://vimeo.com/*", "https://vimeo.com/*/*/videos/*", "https://player.vimeo.com/video/*"},
+		URL:     "https://vimeo.com/api/oembed.{format}", Discovery: true}}},
+	{Name: "SoundCloud", URL: "https://soundcloud.com/", Endpoints: []*OEmbedEndpoint{{
+		Schemes: []string{"http://soundcloud.com/*", "https://soundcloud.com/*", "https://on.soundcloud.com/*"},
+		URL:     "https://soundcloud.com/oembed"}}},
+	{Name: "Twitter", URL: "https://twitter.com/", Endpoints: []*OEmbedEndpoint{{
+		Schemes: []string{"https://twitter.com/*/status/*", "https://*.twitter.com/*/status/*", "https://x.com/*/status/*"},
+		URL:     "https://publish.twitter.com/oembed"}}},
+	{Name: "Flickr", URL: "https://www.flickr.com/", Endpoints: []*OEmbedEndpoint{{
+		Schemes: []string{"http://*.flickr.com/photos/*", "https://*.flickr.com/photos/*", "https://flic.kr/p/*"},
+		URL:     "https://www.flickr.com/services/oembed/", Discovery: true}}},
+	{Name: "Spotify", URL: "https://spotify.com/", Endpoints: []*OEmbedEndpoint{{
+		Schemes: []string{"https://open.spotify.com/*", "spotify:*"},
+		URL:     "https://open.spotify.com/oembed/"}}},
+}
+
+// LoadOEmbedProviders reads a provider registry file in the format of https://oembed.com/providers.json
+func LoadOEmbedProviders(fileName string) ([]*OEmbedProvider, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var result []*OEmbedProvider
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, &ParseError{URL: fileName, Subject: "oEmbed providers", Err: err}
+	}
+	return result, nil
+}
+
+// DefaultOEmbedCacheTTL is how long oEmbed responses are cached when neither the client options nor
+// the response's cache_age say otherwise
+const DefaultOEmbedCacheTTL = 24 * time.Hour
+
+// MaxOEmbedCacheAge is the longest a response's cache_age may keep it in the cache; providers choose
+// cache_age, so larger values (which would also overflow time.Duration) are cut down to it
+const MaxOEmbedCacheAge = 30 * 24 * time.Hour
+
+// DefaultOEmbedCacheSize is how many responses are cached when the client options don't say otherwise
+const DefaultOEmbedCacheSize = 1000
+
+// DefaultOEmbedTimeout is how long an oEmbed request may take when the client options don't say otherwise
+const DefaultOEmbedTimeout = 10 * time.Second
+
+// maxOEmbedResponseLength is how large an oEmbed response may be; responses are small, so anything
+// larger is not an oEmbed response
+const maxOEmbedResponseLength = 1 << 20
+
+// OEmbedClientOptions configures an OEmbedClient
+type OEmbedClientOptions struct {
+	// Providers is the registry of providers; when nil, DefaultOEmbedProviders is used
+	Providers []*OEmbedProvider
+
+	// AllowedProviders lists the provider names (e.g. YouTube) or endpoint hosts (e.g. vimeo.com) that may
+	// be requested; when empty, only the endpoints of registered providers are requested
+	AllowedProviders []string
+
+	// DiscoverEndpoints uses the oEmbed <link> tags of HTML pages for URLs the registry doesn't match.
+	// Since pages choose these URLs, a discovered endpoint is only requested if it's on the host of a
+	// registered endpoint or AllowedProviders allows it.
+	DiscoverEndpoints bool
+
+	// MaxWidth and MaxHeight, when not zero, are passed to providers to limit the size of embeds
+	MaxWidth  int
+	MaxHeight int
+
+	// CacheTTL is how long responses are cached; when zero, a response's cache_age (at most
+	// MaxOEmbedCacheAge) or else DefaultOEmbedCacheTTL is used
+	CacheTTL time.Duration
+
+	// CacheSize is how many responses are cached; when zero, DefaultOEmbedCacheSize is used
+	CacheSize int
+
+	// Timeout is how long a request may take; when zero, DefaultOEmbedTimeout is used
+	Timeout time.Duration
+}
+
+// OEmbedClient retrieves oEmbed responses for harvested resources from registered or discovered endpoints
+type OEmbedClient struct {
+	options    OEmbedClientOptions
+	schemes    []*oEmbedScheme
+	httpClient *http.Client
+
+	cacheMutex sync.Mutex
+	cache      map[string]*oEmbedCacheEntry // keyed by request URL
+}
+
+type oEmbedScheme struct {
+	provider *OEmbedProvider
+	endpoint *OEmbedEndpoint
+	regEx    *regexp.Regexp
+}
+
+type oEmbedCacheEntry struct {
+	oEmbed  *OEmbed
+	expires time.Time
+}
+
+// MakeOEmbedClient prepares a client using the given options
+func MakeOEmbedClient(options OEmbedClientOptions) *OEmbedClient {
+	result := new(OEmbedClient)
+	result.options = options
+	if result.options.Providers == nil {
+		result.options.Providers = DefaultOEmbedProviders
+	}
+	for _, provider := range result.options.Providers {
+		for _, endpoint := range provider.Endpoints {
+			for _, scheme := range endpoint.Schemes {
+				pattern := "^" + strings.Replace(regexp.QuoteMeta(scheme), `\*`, ".*", -1) + "$"
+				result.schemes = append(result.schemes, &oEmbedScheme{provider: provider, endpoint: endpoint, regEx: regexp.MustCompile(pattern)})
+			}
+		}
+	}
+	if result.options.CacheSize == 0 {
+		result.options.CacheSize = DefaultOEmbedCacheSize
+	}
+	if result.options.Timeout == 0 {
+		result.options.Timeout = DefaultOEmbedTimeout
+	}
+	result.httpClient = &http.Client{Timeout: result.options.Timeout}
+	result.cache = make(map[string]*oEmbedCacheEntry)
+	return result
+}
+
+// MakeDefaultOEmbedClient prepares a client for DefaultOEmbedProviders which also discovers endpoints
+// on the hosts of those providers
+func MakeDefaultOEmbedClient() *OEmbedClient {
+	return MakeOEmbedClient(OEmbedClientOptions{DiscoverEndpoints: true})
+}
+
+// Fetch returns the oEmbed response for resourceURL, or nil if no allowed provider serves it. The
+// registry is consulted first; content (which may be nil) is used to discover an endpoint otherwise.
+func (c *OEmbedClient) Fetch(resourceURL *url.URL, content *HarvestedResourceContent, o observe.Observatory, parentSpan opentracing.Span) (*OEmbed, error) {
+	if resourceURL == nil {
+		return nil, nil
+	}
+	span := o.StartChildTrace("fetchOEmbed", parentSpan)
+	defer span.Finish()
+
+	requestURL, provider, found := c.requestURL(resourceURL, content)
+	if !found {
+		span.LogFields(log.String("provider", "none"))
+		return nil, nil
+	}
+	span.LogFields(log.String("provider", provider), log.String("requestURL", requestURL.String()))
+
+	if cached, found := c.cached(requestURL.String()); found {
+		span.LogFields(log.Bool("isCached", true))
+		return cached, nil
+	}
+
+	result, err := c.request(requestURL)
+	if err != nil {
+		opentrext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+		return nil, err
+	}
+	c.store(requestURL.String(), result)
+	return result, nil
+}
+
+// requestURL returns the allowed endpoint URL to request and the name of the provider (or the endpoint
+// host for discovered endpoints which aren't registered)
+func (c *OEmbedClient) requestURL(resourceURL *url.URL, content *HarvestedResourceContent) (*url.URL, string, bool) {
+	for _, scheme := range c.schemes {
+		if !scheme.regEx.MatchString(resourceURL.String()) {
+			continue
+		}
+		endpoint, err := url.Parse(strings.Replace(scheme.endpoint.URL, "{format}", "json", -1))
+		if err != nil || !c.isAllowed(scheme.provider.Name, endpoint, true) {
+			continue
+		}
+		query := endpoint.Query()
+		query.Set("url", resourceURL.String())
+		query.Set("format", "json")
+		c.setMaxSize(query)
+		endpoint.RawQuery = query.Encode()
+		return endpoint, scheme.provider.Name, true
+	}
+
+	if !c.options.DiscoverEndpoints || content == nil {
+		return nil, "", false
+	}
+	// prefer JSON, which is what most providers offer
+	var candidates []*url.URL
+	for _, preferJSON := range []bool{true, false} {
+		for _, link := range content.Links().OEmbedEndpoints() {
+			if strings.Contains(strings.ToLower(link.Type), "json") != preferJSON {
+				continue
+			}
+			if endpoint, err := url.Parse(link.Href); err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") {
+				candidates = append(candidates, endpoint)
+			}
+		}
+	}
+	for _, endpoint := range candidates {
+		provider, registered := c.providerName(endpoint)
+		if !c.isAllowed(provider, endpoint, registered) {
+			continue
+		}
+		query := endpoint.Query()
+		c.setMaxSize(query)
+		endpoint.RawQuery = query.Encode()
+		return endpoint, provider, true
+	}
+	return nil, "", false
+}
+
+func (c *OEmbedClient) setMaxSize(query url.Values) {
+	if c.options.MaxWidth > 0 {
+		query.Set("maxwidth", strconv.Itoa(c.options.MaxWidth))
+	}
+	if c.options.MaxHeight > 0 {
+		query.Set("maxheight", strconv.Itoa(c.options.MaxHeight))
+	}
+}
+
+// providerName returns the name of the registered provider with an endpoint on the same host and true,
+// or the host and false
+func (c *OEmbedClient) providerName(endpoint *url.URL) (string, bool) {
+	for _, provider := range c.options.Providers {
+		for _, registered := range provider.Endpoints {
+			if registeredURL, err := url.Parse(registered.URL); err == nil && strings.EqualFold(registeredURL.Hostname(), endpoint.Hostname()) {
+				return provider.Name, true
+			}
+		}
+	}
+	return strings.ToLower(endpoint.Hostname()), false
+}
+
+// isAllowed returns true if the endpoint may be requested; without AllowedProviders only registered
+// providers may be
+func (c *OEmbedClient) isAllowed(provider string, endpoint *url.URL, registered bool) bool {
+	if len(c.options.AllowedProviders) == 0 {
+		return registered
+	}
+	host := strings.ToLower(endpoint.Hostname())
+	for _, allowed := range c.options.AllowedProviders {
+		allowed = strings.ToLower(allowed)
+		if strings.EqualFold(provider, allowed) || host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OEmbedClient) request(requestURL *url.URL) (*OEmbed, error) {
+	resp, err := c.httpClient.Get(requestURL.String())
+	if err != nil {
+		return nil, classifyRequestError(requestURL.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{URL: requestURL.String(), StatusCode: resp.StatusCode}
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxOEmbedResponseLength+1))
+	if err != nil {
+		return nil, classifyRequestError(requestURL.String(), err)
+	}
+	if len(data) > maxOEmbedResponseLength {
+		return nil, &TooLargeError{URL: requestURL.String(), Limit: maxOEmbedResponseLength}
+	}
+	result := new(OEmbed)
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "xml") {
+		err = xml.Unmarshal(data, result)
+	} else {
+		err = json.Unmarshal(data, result)
+	}
+	if err != nil {
+		return nil, &ParseError{URL: requestURL.String(), Subject: "oEmbed", Err: err}
+	}
+	return result, nil
+}
+
+func (c *OEmbedClient) cached(key string) (*OEmbed, bool) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+	entry, found := c.cache[key]
+	if !found {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.cache, key)
+		return nil, false
+	}
+	return entry.oEmbed, true
+}
+
+func (c *OEmbedClient) store(key string, oEmbed *OEmbed) {
+	ttl := c.options.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultOEmbedCacheTTL
+		if oEmbed.CacheAge > 0 {
+			ttl = MaxOEmbedCacheAge
+			if oEmbed.CacheAge < int(MaxOEmbedCacheAge/time.Second) {
+				ttl = time.Duration(oEmbed.CacheAge) * time.Second
+			}
+		}
+	}
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+	if _, found := c.cache[key]; !found && len(c.cache) >= c.options.CacheSize {
+		c.evict()
+	}
+	c.cache[key] = &oEmbedCacheEntry{oEmbed: oEmbed, expires: time.Now().Add(ttl)}
+}
+
+// evict makes room in the full cache by removing the expired entries or, if none have expired, the
+// entry which expires first; the cache mutex must be held
+func (c *OEmbedClient) evict() {
+	now := time.Now()
+	var first string
+	var firstExpires time.Time
+	for key, entry := range c.cache {
+		if now.After(entry.expires) {
+			delete(c.cache, key)
+		} else if len(first) == 0 || entry.expires.Before(firstExpires) {
+			first, firstExpires = key, entry.expires
+		}
+	}
+	if len(c.cache) >= c.options.CacheSize {
+		delete(c.cache, first)
+	}
+}
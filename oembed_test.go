@@ -0,0 +1,198 @@
+package harvester
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OEmbedSuite struct {
+	harvesterSuite
+	requests int32
+}
+
+func (suite *OEmbedSuite) SetupSuite() {
+	suite.setupSuite(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/video":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, `<html><head><title>A Video</title>
+				<link rel="alternate" type="text/xml+oembed" href="/oembed.xml?url=%[1]s/video" />
+				<link rel="alternate" type="application/json+oembed" href="/oembed.json?url=%[1]s/video" />
+				</head><body></body></html>`, suite.server.URL)
+		case "/oembed.json":
+			atomic.AddInt32(&suite.requests, 1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"type": "video", "version": "1.0", "title": "A Video", "author_name": "Jane Filmmaker",
+				"provider_name": "Example Video", "html": "<iframe src=\"%s/embed\"></iframe>", "width": "640", "height": 360,
+				"thumbnail_url": "%s/thumb.jpg", "maxwidth": "%s"}`, suite.server.URL, suite.server.URL, r.URL.Query().Get("maxwidth"))
+		case "/oembed-huge.json":
+			atomic.AddInt32(&suite.requests, 1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"type": "rich", "html": "%s"}`, strings.Repeat("x", maxOEmbedResponseLength))
+		case "/oembed.xml":
+			atomic.AddInt32(&suite.requests, 1)
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><oembed><type>photo</type><version>1.0</version>
+				<url>%s/photo.jpg</url><width>800</width><height>600</height><title>%s</title></oembed>`, suite.server.URL, r.URL.Query().Get("url"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func (suite *OEmbedSuite) SetupTest() {
+	atomic.StoreInt32(&suite.requests, 0)
+}
+
+// registry writes a provider registry file which sends /photos/* URLs to the XML endpoint
+func (suite *OEmbedSuite) registry() []*OEmbedProvider {
+	dir, err := ioutil.TempDir("", "harvester-oembed-")
+	suite.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "providers.json")
+	suite.Require().NoError(ioutil.WriteFile(fileName, []byte(fmt.Sprintf(`[{"provider_name": "Example Photos",
+		"provider_url": "%[1]s", "endpoints": [{"schemes": ["%[1]s/photos/*"], "url": "%[1]s/oembed.xml"}]}]`, suite.server.URL)), 0644))
+	providers, err := LoadOEmbedProviders(fileName)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, len(providers))
+	return providers
+}
+
+func (suite *OEmbedSuite) TestDiscoveredEndpointAttachedToResource() {
+	client := MakeOEmbedClient(OEmbedClientOptions{DiscoverEndpoints: true, AllowedProviders: []string{"127.0.0.1"}, MaxWidth: 480})
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{OEmbedClient: client})
+	defer ch.Close()
+
+	harvested := ch.HarvestResources(fmt.Sprintf("Watch %s/video in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	oEmbed := harvested.Resources[0].OEmbed()
+	suite.Require().NotNil(oEmbed, "The JSON endpoint should be preferred")
+	suite.Equal("video", oEmbed.Type)
+	suite.Equal("Jane Filmmaker", oEmbed.AuthorName)
+	suite.Equal("Example Video", oEmbed.ProviderName)
+	suite.Equal(fmt.Sprintf(`<iframe src="%s/embed"></iframe>`, suite.server.URL), oEmbed.HTML)
+	suite.Equal(suite.server.URL+"/thumb.jpg", oEmbed.ThumbnailURL)
+	suite.Equal(640, oEmbed.Width, "Numbers sent as strings should be accepted")
+	suite.Equal(360, oEmbed.Height)
+}
+
+func (suite *OEmbedSuite) TestDiscoveredEndpointNotAllowed() {
+	client := MakeOEmbedClient(OEmbedClientOptions{DiscoverEndpoints: true})
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{OEmbedClient: client})
+	defer ch.Close()
+
+	harvested := ch.HarvestResources(fmt.Sprintf("Watch %s/video in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	suite.Nil(harvested.Resources[0].OEmbed(), "Without an allow list only registered providers should be requested")
+	suite.Equal(int32(0), atomic.LoadInt32(&suite.requests))
+
+	client = MakeOEmbedClient(OEmbedClientOptions{DiscoverEndpoints: true, AllowedProviders: []string{"example.com"}})
+	videoURL, _ := url.Parse(suite.server.URL + "/video")
+	_, _, found := client.requestURL(videoURL, harvested.Resources[0].ResourceContent())
+	suite.False(found, "Discovered endpoints should be filtered by the allow list")
+}
+
+func (suite *OEmbedSuite) TestResponseSizeLimit() {
+	providers := suite.registry()
+	providers[0].Endpoints[0].URL = suite.server.URL + "/oembed-huge.json"
+	client := MakeOEmbedClient(OEmbedClientOptions{Providers: providers})
+	photoURL, _ := url.Parse(suite.server.URL + "/photos/42")
+	oEmbed, err := client.Fetch(photoURL, nil, suite.observatory, suite.span)
+	suite.Nil(oEmbed)
+	suite.IsType(&TooLargeError{}, err)
+}
+
+func (suite *OEmbedSuite) TestCacheSize() {
+	client := MakeOEmbedClient(OEmbedClientOptions{Providers: suite.registry(), CacheSize: 2})
+	for i := 0; i < 5; i++ {
+		photoURL, _ := url.Parse(fmt.Sprintf("%s/photos/%d", suite.server.URL, i))
+		_, err := client.Fetch(photoURL, nil, suite.observatory, suite.span)
+		suite.Require().NoError(err)
+		suite.True(len(client.cache) <= 2, "The cache should not grow beyond its size")
+	}
+	suite.Equal(int32(5), atomic.LoadInt32(&suite.requests))
+}
+
+func (suite *OEmbedSuite) TestCacheAgeIsCapped() {
+	client := MakeOEmbedClient(OEmbedClientOptions{Providers: suite.registry()})
+	for _, cacheAge := range []int{math.MaxInt64, math.MaxInt64 / int(time.Second) * 2, int(MaxOEmbedCacheAge/time.Second) + 1} {
+		before := time.Now()
+		client.store("https://example.com/oembed", &OEmbed{CacheAge: cacheAge})
+		expires := client.cache["https://example.com/oembed"].expires
+		suite.True(expires.After(before.Add(MaxOEmbedCacheAge-time.Minute)), "cache_age %d", cacheAge)
+		suite.False(expires.After(time.Now().Add(MaxOEmbedCacheAge)), "cache_age %d", cacheAge)
+	}
+	client.store("https://example.com/oembed", &OEmbed{CacheAge: 60})
+	suite.True(client.cache["https://example.com/oembed"].expires.Before(time.Now().Add(61 * time.Second)))
+}
+
+func (suite *OEmbedSuite) TestRegistryAndCaching() {
+	client := MakeOEmbedClient(OEmbedClientOptions{Providers: suite.registry()})
+	photoURL, _ := url.Parse(suite.server.URL + "/photos/42")
+
+	oEmbed, err := client.Fetch(photoURL, nil, suite.observatory, suite.span)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(oEmbed)
+	suite.Equal("photo", oEmbed.Type)
+	suite.Equal(suite.server.URL+"/photo.jpg", oEmbed.URL)
+	suite.Equal(800, oEmbed.Width)
+	suite.Equal(photoURL.String(), oEmbed.Title, "The resource URL should be passed to the endpoint")
+
+	again, err := client.Fetch(photoURL, nil, suite.observatory, suite.span)
+	suite.NoError(err)
+	suite.Equal(oEmbed, again)
+	suite.Equal(int32(1), atomic.LoadInt32(&suite.requests), "The second response should come from the cache")
+
+	otherURL, _ := url.Parse(suite.server.URL + "/articles/42")
+	none, err := client.Fetch(otherURL, nil, suite.observatory, suite.span)
+	suite.NoError(err)
+	suite.Nil(none, "Discovery is off so unregistered URLs have no provider")
+}
+
+func (suite *OEmbedSuite) TestAllowList() {
+	client := MakeOEmbedClient(OEmbedClientOptions{Providers: suite.registry(), AllowedProviders: []string{"YouTube", "vimeo.com"}})
+	photoURL, _ := url.Parse(suite.server.URL + "/photos/42")
+	oEmbed, err := client.Fetch(photoURL, nil, suite.observatory, suite.span)
+	suite.NoError(err)
+	suite.Nil(oEmbed)
+	suite.Equal(int32(0), atomic.LoadInt32(&suite.requests))
+
+	client = MakeOEmbedClient(OEmbedClientOptions{Providers: suite.registry(), AllowedProviders: []string{"example photos"}})
+	oEmbed, err = client.Fetch(photoURL, nil, suite.observatory, suite.span)
+	suite.NoError(err)
+	suite.NotNil(oEmbed)
+}
+
+func (suite *OEmbedSuite) TestEndpointError() {
+	providers := suite.registry()
+	providers[0].Endpoints[0].URL = suite.server.URL + "/missing"
+	client := MakeOEmbedClient(OEmbedClientOptions{Providers: providers})
+	photoURL, _ := url.Parse(suite.server.URL + "/photos/42")
+	oEmbed, err := client.Fetch(photoURL, nil, suite.observatory, suite.span)
+	suite.Nil(oEmbed)
+	suite.IsType(&HTTPStatusError{}, err)
+}
+
+func (suite *OEmbedSuite) TestDefaultRegistry() {
+	client := MakeDefaultOEmbedClient()
+	youTubeURL, _ := url.Parse("https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	requestURL, provider, found := client.requestURL(youTubeURL, nil)
+	suite.True(found)
+	suite.Equal("YouTube", provider)
+	suite.Equal("https://www.youtube.com/oembed?format=json&url=https%3A%2F%2Fwww.youtube.com%2Fwatch%3Fv%3DdQw4w9WgXcQ", requestURL.String())
+}
+
+func TestOEmbedSuite(t *testing.T) {
+	suite.Run(t, new(OEmbedSuite))
+}
@@ -0,0 +1,350 @@
+package harvester
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lectio/observe"
+	"github.com/ledongthuc/pdf"
+	opentracing "github.com/opentracing/opentracing-go"
+	opentrext "github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// DefaultPDFTextPages is how many pages of text MakeDefaultPDFInspector extracts
+const DefaultPDFTextPages = 3
+
+// DefaultMaxPDFFileSize is the size of the largest PDF MakeDefaultPDFInspector inspects
+const DefaultMaxPDFFileSize int64 = 50 << 20
+
+// maxPDFTextLength caps the extracted text, in bytes, so that a few dense pages can't take up too much memory
+const maxPDFTextLength = 64 * 1024
+
+// pdfPageSeparator separates the text of pages, see PDFDocument.Text
+const pdfPageSeparator = "\n\n"
+
+// maxPDFTitleLength is how long a title taken from the first line of text may be, see PDFDocument.DisplayTitle
+const maxPDFTitleLength = 120
+
+// maxXMPLength is how much of the XMP metadata stream is read
+const maxXMPLength = 1 << 20
+
+// PDFDocument is the metadata and text of a downloaded PDF; the metadata comes from the XMP packet of the
+// document, falling back to its info dictionary for anything the XMP doesn't have
+type PDFDocument struct {
+	Title      string    `json:"title,omitempty"`
+	Author     string    `json:"author,omitempty"`
+	Subject    string    `json:"subject,omitempty"`
+	Keywords   string    `json:"keywords,omitempty"`
+	Creator    string    `json:"creator,omitempty"`  // the application which created the original document
+	Producer   string    `json:"producer,omitempty"` // the application which converted it to PDF
+	CreatedOn  time.Time `json:"createdOn"`
+	ModifiedOn time.Time `json:"modifiedOn"`
+	PageCount  int       `json:"pageCount"`
+	TextPages  []string  `json:"textPages,omitempty"` // the text of the first pages, one entry per page
+}
+
+// Text returns the text of the extracted pages separated by blank lines
+func (d *PDFDocument) Text() string {
+	return strings.Join(d.TextPages, pdfPageSeparator)
+}
+
+// DisplayTitle returns the title or, since many PDFs don't have one, the first line of text
+func (d *PDFDocument) DisplayTitle() string {
+	if len(d.Title) > 0 {
+		return d.Title
+	}
+	for _, page := range d.TextPages {
+		for _, line := range strings.Split(page, "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				return truncateText(maxPDFTitleLength, line)
+			}
+		}
+	}
+	return ""
+}
+
+// PDFInspector reads the metadata, page count and text of downloaded PDFs
+type PDFInspector struct {
+	maxTextPages int
+	maxFileSize  int64
+}
+
+// MakePDFInspector prepares an inspector which extracts the text of up to maxTextPages pages of PDFs no
+// larger than maxFileSize bytes; when maxTextPages is zero no text is extracted and when maxFileSize is
+// zero PDFs of any size are inspected
+func MakePDFInspector(maxTextPages int, maxFileSize int64) *PDFInspector {
+	result := new(PDFInspector)
+	result.maxTextPages = maxTextPages
+	result.maxFileSize = maxFileSize
+	return result
+}
+
+// MakeDefaultPDFInspector prepares an inspector which extracts the text of the first DefaultPDFTextPages
+// pages of PDFs up to DefaultMaxPDFFileSize bytes
+func MakeDefaultPDFInspector() *PDFInspector {
+	return MakePDFInspector(DefaultPDFTextPages, DefaultMaxPDFFileSize)
+}
+
+// Inspect reads the PDF at path; a *ParseError with the subject "PDF" is returned if it can't be read
+// and a *TooLargeError if it's larger than the inspector's maximum file size
+func (i *PDFInspector) Inspect(path string) (*PDFDocument, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return i.InspectReader(file, info.Size())
+}
+
+// InspectReader reads a PDF of the given size, see Inspect
+func (i *PDFInspector) InspectReader(reader io.ReaderAt, size int64) (result *PDFDocument, err error) {
+	if i.maxFileSize > 0 && size > i.maxFileSize {
+		return nil, &TooLargeError{Limit: i.maxFileSize}
+	}
+
+	// the PDF reader panics on many kinds of malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = &ParseError{Subject: "PDF", Err: fmt.Errorf("%v", r)}
+		}
+	}()
+
+	document, err := pdf.NewReader(reader, size)
+	if err != nil {
+		return nil, &ParseError{Subject: "PDF", Err: err}
+	}
+
+	result = new(PDFDocument)
+	result.PageCount = document.NumPage()
+	result.readMetadata(document)
+	length := 0
+	for page := 1; page <= result.PageCount && page <= i.maxTextPages; page++ {
+		text, err := document.Page(page).GetPlainText(nil)
+		if err != nil {
+			// a page with an unsupported font or encoding shouldn't lose the pages before it
+			break
+		}
+		if len(result.TextPages) > 0 {
+			length += len(pdfPageSeparator)
+		}
+		text = truncateUTF8(normalizePDFText(text), maxPDFTextLength-length)
+		if len(text) == 0 {
+			break
+		}
+		result.TextPages = append(result.TextPages, text)
+		length += len(text)
+	}
+	return result, nil
+}
+
+// truncateUTF8 returns at most maxBytes bytes of text without cutting a rune in half
+func truncateUTF8(text string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	if len(text) <= maxBytes {
+		return text
+	}
+	for maxBytes > 0 && !utf8.RuneStart(text[maxBytes]) {
+		maxBytes--
+	}
+	return text[:maxBytes]
+}
+
+// readMetadata fills in the metadata from the XMP packet and the info dictionary, preferring the XMP
+func (d *PDFDocument) readMetadata(document *pdf.Reader) {
+	var xmp map[string][]string
+	if stream := document.Trailer().Key("Root").Key("Metadata"); !stream.IsNull() {
+		reader := stream.Reader()
+		if data, err := ioutil.ReadAll(io.LimitReader(reader, maxXMPLength)); err == nil {
+			xmp = parseXMP(data)
+		}
+		reader.Close()
+	}
+	first := func(key string) string {
+		if values := xmp[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	info := document.Trailer().Key("Info")
+	text := func(key string) string {
+		return strings.TrimSpace(info.Key(key).Text())
+	}
+
+	d.Title = firstNonEmpty(first("dc:title"), text("Title"))
+	d.Author = firstNonEmpty(strings.Join(xmp["dc:creator"], ", "), text("Author"))
+	d.Subject = firstNonEmpty(first("dc:description"), text("Subject"))
+	d.Keywords = firstNonEmpty(first("pdf:Keywords"), strings.Join(xmp["dc:subject"], ", "), text("Keywords"))
+	d.Creator = firstNonEmpty(first("xmp:CreatorTool"), text("Creator"))
+	d.Producer = firstNonEmpty(first("pdf:Producer"), text("Producer"))
+
+	var ok bool
+	if d.CreatedOn, ok = parseArticleDate(first("xmp:CreateDate")); !ok {
+		d.CreatedOn, _ = parsePDFDate(text("CreationDate"))
+	}
+	if d.ModifiedOn, ok = parseArticleDate(first("xmp:ModifyDate")); !ok {
+		d.ModifiedOn, _ = parsePDFDate(text("ModDate"))
+	}
+}
+
+// pdfDateLayouts are the forms of "D:YYYYMMDDHHmmSSOHH'mm'" with the apostrophes removed, most precise first
+var pdfDateLayouts = []string{"20060102150405Z0700", "20060102150405", "200601021504Z0700", "200601021504",
+	"2006010215", "20060102", "200601", "2006"}
+
+// parsePDFDate parses a PDF date string such as D:20190405083000+02'00'
+func parsePDFDate(value string) (time.Time, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	value = strings.Replace(value, "'", "", -1)
+	if index := strings.IndexByte(value, 'Z'); index >= 0 {
+		// some producers write Z00'00'
+		value = value[:index+1]
+	}
+	for _, layout := range pdfDateLayouts {
+		if result, err := time.Parse(layout, value); err == nil {
+			return result, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// xmpNamespaces are the XMP schemas metadata is read from and the prefixes parseXMP uses for them
+var xmpNamespaces = map[string]string{
+	"http://purl.org/dc/elements/1.1/": "dc",
+	"http://ns.adobe.com/xap/1.0/":     "xmp",
+	"http://ns.adobe.com/pdf/1.3/":     "pdf",
+}
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// parseXMP returns the values of the properties in xmpNamespaces, keyed by prefix and name (e.g. dc:title);
+// properties may be attributes of rdf:Description, elements with text or rdf:Alt, rdf:Bag and rdf:Seq lists
+func parseXMP(data []byte) map[string][]string {
+	result := make(map[string][]string)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var property string
+	var depth, propertyDepth int
+	var text strings.Builder
+	var values []string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return result
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case len(property) > 0:
+				if token.Name.Space == rdfNamespace && token.Name.Local == "li" {
+					text.Reset()
+				}
+			case token.Name.Space == rdfNamespace && token.Name.Local == "Description":
+				for _, attr := range token.Attr {
+					if key := xmpKey(attr.Name); len(key) > 0 && len(strings.TrimSpace(attr.Value)) > 0 {
+						result[key] = append(result[key], strings.TrimSpace(attr.Value))
+					}
+				}
+			default:
+				if key := xmpKey(token.Name); len(key) > 0 {
+					property, propertyDepth = key, depth
+					values = nil
+					text.Reset()
+				}
+			}
+		case xml.CharData:
+			if len(property) > 0 {
+				text.Write(token)
+			}
+		case xml.EndElement:
+			if len(property) > 0 {
+				value := strings.TrimSpace(text.String())
+				switch {
+				case depth == propertyDepth:
+					if len(values) == 0 && len(value) > 0 {
+						values = append(values, value)
+					}
+					result[property] = append(result[property], values...)
+					property = ""
+				case token.Name.Space == rdfNamespace && token.Name.Local == "li":
+					if len(value) > 0 {
+						values = append(values, value)
+					}
+					text.Reset()
+				}
+			}
+			depth--
+		}
+	}
+}
+
+func xmpKey(name xml.Name) string {
+	if prefix, ok := xmpNamespaces[name.Space]; ok {
+		return prefix + ":" + name.Local
+	}
+	return ""
+}
+
+// normalizePDFText collapses the whitespace within each line and removes blank lines
+func normalizePDFText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+// inspectPDF attaches the metadata and text of downloaded PDF content, see ContentDetectionOptions.PDFInspector
+func (c *HarvestedResourceContent) inspectPDF(inspector *PDFInspector, o observe.Observatory, parentSpan opentracing.Span) {
+	span := o.StartChildTrace("inspectPDF", parentSpan)
+	defer span.Finish()
+
+	document, err := inspector.Inspect(c.downloaded.DestPath)
+	if err != nil {
+		switch err := err.(type) {
+		case *ParseError:
+			err.URL = urlText(c.url)
+		case *TooLargeError:
+			err.URL = urlText(c.url)
+		}
+		c.pdfError = err
+		opentrext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+		return
+	}
+	c.pdf = document
+	span.LogFields(log.String("title", document.Title), log.Int("pageCount", document.PageCount))
+}
+
+// PDF returns the metadata and text of downloaded PDF content and the error encountered reading it, if any;
+// both are nil unless the content was detected with ContentDetectionOptions.PDFInspector
+func (c HarvestedResourceContent) PDF() (*PDFDocument, error) {
+	return c.pdf, c.pdfError
+}
@@ -0,0 +1,215 @@
+package harvester
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// buildTestPDF writes a minimal PDF with the given info dictionary entries, XMP packet (if any) and one
+// page for each text, with one line per line of the text
+func buildTestPDF(info string, xmp string, pages ...string) string {
+	var objects []string
+	add := func(object string) int {
+		objects = append(objects, object)
+		return len(objects)
+	}
+	stream := func(dict, data string) string {
+		return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+	}
+
+	catalog := add("")
+	pagesTree := add("")
+	font := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	var kids []string
+	for _, text := range pages {
+		var content strings.Builder
+		content.WriteString("BT /F1 12 Tf 72 720 Td 14 TL")
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(&content, " (%s) Tj T*", line)
+		}
+		content.WriteString(" ET")
+		contents := add(stream("", content.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>", pagesTree, font, contents))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[pagesTree-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+	objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesTree)
+	if len(xmp) > 0 {
+		metadata := add(stream("/Type /Metadata /Subtype /XML", xmp))
+		objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /Metadata %d 0 R >>", pagesTree, metadata)
+	}
+	infoObject := add(fmt.Sprintf("<< %s >>", info))
+
+	var result strings.Builder
+	result.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = result.Len()
+		fmt.Fprintf(&result, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := result.Len()
+	fmt.Fprintf(&result, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&result, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&result, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalog, infoObject, xref)
+	return result.String()
+}
+
+const testReportXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/" pdf:Producer="Test Distiller">
+   <pdf:Keywords>harvesting, documents</pdf:Keywords>
+  </rdf:Description>
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Quarterly Harvest Report</rdf:li></rdf:Alt></dc:title>
+   <dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li><rdf:li>John Roe</rdf:li></rdf:Seq></dc:creator>
+  </rdf:Description>
+  <rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+   <xmp:ModifyDate>2019-04-06T10:00:00Z</xmp:ModifyDate>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+var (
+	testReportPDF = buildTestPDF("/Title (Untitled) /Author (Nobody) /Subject (Results of the harvest) /CreationDate (D:20190405083000+02'00') /ModDate (D:20190405090000Z)",
+		testReportXMP, "Quarterly Harvest Report\nFirst page", "Second page", "Third page", "Fourth page")
+	testUntitledPDF = buildTestPDF("/Producer (Scanner)", "", "Minutes of the annual meeting\nAttendees")
+)
+
+var pdfTestFixtures = testFixtures{files: map[string][2]string{
+	"/doc.pdf":      {"application/pdf", emptyTestPDF},
+	"/report.pdf":   {"application/pdf", testReportPDF},
+	"/untitled.pdf": {"application/pdf", testUntitledPDF},
+}}
+
+type PDFSuite struct {
+	harvesterSuite
+}
+
+func (suite *PDFSuite) SetupSuite() {
+	suite.setupSuite(pdfTestFixtures.handler())
+}
+
+func (suite *PDFSuite) harvest(options ContentHarvesterOptions, path string) (*ContentHarvester, *HarvestedResource) {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, options)
+	harvested := ch.HarvestResources(fmt.Sprintf("Document %s%s in a mock tweet", suite.server.URL, path), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return ch, harvested.Resources[0]
+}
+
+func (suite *PDFSuite) TestMetadataAndText() {
+	ch, resource := suite.harvest(ContentHarvesterOptions{PDFInspector: MakeDefaultPDFInspector()}, "/report.pdf")
+	defer ch.Close()
+	content := resource.ResourceContent()
+	suite.True(content.IsPDF())
+
+	document, err := content.PDF()
+	suite.Require().NoError(err)
+	suite.Require().NotNil(document)
+	suite.Equal("Quarterly Harvest Report", document.Title, "XMP should be preferred to the info dictionary")
+	suite.Equal("Jane Doe, John Roe", document.Author)
+	suite.Equal("Results of the harvest", document.Subject, "Info dictionary should fill in what XMP doesn't have")
+	suite.Equal("harvesting, documents", document.Keywords)
+	suite.Equal("Test Distiller", document.Producer, "XMP properties may be attributes")
+	suite.True(document.CreatedOn.Equal(time.Date(2019, 4, 5, 6, 30, 0, 0, time.UTC)), document.CreatedOn.String())
+	suite.True(document.ModifiedOn.Equal(time.Date(2019, 4, 6, 10, 0, 0, 0, time.UTC)), document.ModifiedOn.String())
+	suite.Equal(4, document.PageCount)
+	suite.Equal(DefaultPDFTextPages, len(document.TextPages))
+	suite.Contains(document.TextPages[0], "First page")
+	suite.Contains(document.Text(), "Third page")
+	suite.NotContains(document.Text(), "Fourth page")
+
+	keys := CreateHarvestedResourceKeys(resource, func(random uint32, try int) bool { return false })
+	suite.Equal("Quarterly Harvest Report", keys.Title())
+	suite.Equal("Results of the harvest", keys.Description())
+	suite.Equal("quarterly-harvest-report", keys.Slug())
+}
+
+func (suite *PDFSuite) TestTitleFromText() {
+	ch, resource := suite.harvest(ContentHarvesterOptions{PDFInspector: MakeDefaultPDFInspector()}, "/untitled.pdf")
+	defer ch.Close()
+
+	document, err := resource.ResourceContent().PDF()
+	suite.Require().NoError(err)
+	suite.Equal("", document.Title)
+	suite.Equal("Scanner", document.Producer)
+	suite.Equal("Minutes of the annual meeting", document.DisplayTitle())
+	suite.Equal("minutes-of-the-annual-meeting", CreateHarvestedResourceKeys(resource, func(random uint32, try int) bool { return false }).Slug())
+}
+
+func (suite *PDFSuite) TestInvalidPDF() {
+	ch, resource := suite.harvest(ContentHarvesterOptions{PDFInspector: MakeDefaultPDFInspector()}, "/doc.pdf")
+	defer ch.Close()
+	content := resource.ResourceContent()
+
+	document, err := content.PDF()
+	suite.Nil(document)
+	suite.Require().Error(err)
+	var parseErr *ParseError
+	suite.True(errors.As(err, &parseErr))
+	suite.Equal("PDF", parseErr.Subject)
+	suite.NoError(content.Err(), "A PDF which can't be inspected is still valid content")
+}
+
+func (suite *PDFSuite) TestNotInspectedByDefault() {
+	ch, resource := suite.harvest(ContentHarvesterOptions{}, "/report.pdf")
+	defer ch.Close()
+
+	document, err := resource.ResourceContent().PDF()
+	suite.Nil(document)
+	suite.NoError(err)
+}
+
+func (suite *PDFSuite) TestTooLargeNotInspected() {
+	ch, resource := suite.harvest(ContentHarvesterOptions{PDFInspector: MakePDFInspector(DefaultPDFTextPages, 100)}, "/report.pdf")
+	defer ch.Close()
+	content := resource.ResourceContent()
+
+	document, err := content.PDF()
+	suite.Nil(document)
+	var tooLargeErr *TooLargeError
+	suite.Require().True(errors.As(err, &tooLargeErr))
+	suite.Equal(int64(100), tooLargeErr.Limit)
+	suite.Equal(suite.server.URL+"/report.pdf", tooLargeErr.URL)
+	suite.NoError(content.Err())
+}
+
+func (suite *PDFSuite) TestTextLengthLimit() {
+	page := strings.Repeat("word ", 8000)
+	data := buildTestPDF("/Title (Long)", "", page, page)
+	document, err := MakeDefaultPDFInspector().InspectReader(strings.NewReader(data), int64(len(data)))
+	suite.Require().NoError(err)
+	suite.Equal(2, len(document.TextPages))
+	suite.Equal(maxPDFTextLength, len(document.Text()), "The text should be cut off at the limit")
+
+	suite.Equal("h", truncateUTF8("héllo", 2), "A rune should not be cut in half")
+	suite.Equal("hé", truncateUTF8("héllo", 3))
+	suite.Equal("", truncateUTF8("héllo", 0))
+}
+
+func (suite *PDFSuite) TestParsePDFDate() {
+	for value, expected := range map[string]time.Time{
+		"D:20190405083000+02'00'": time.Date(2019, 4, 5, 6, 30, 0, 0, time.UTC),
+		"D:20190405083000Z00'00'": time.Date(2019, 4, 5, 8, 30, 0, 0, time.UTC),
+		"D:20190405083000":        time.Date(2019, 4, 5, 8, 30, 0, 0, time.UTC),
+		"D:201904":                time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		parsed, ok := parsePDFDate(value)
+		suite.True(ok, value)
+		suite.True(expected.Equal(parsed), "%s parsed as %s", value, parsed)
+	}
+	_, ok := parsePDFDate("yesterday")
+	suite.False(ok)
+}
+
+func TestPDFSuite(t *testing.T) {
+	suite.Run(t, new(PDFSuite))
+}
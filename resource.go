@@ -1,6 +1,8 @@
 package harvester
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -29,6 +31,9 @@ type DownloadedContent struct {
 	DownloadError error
 	FileTypeError error
 	FileType      types.Type
+	Size          int64
+	Digest        string // hex encoded SHA-256 digest of the downloaded bytes
+	retained      bool
 }
 
 // Delete removes the file that was downloaded
@@ -39,25 +44,31 @@ func (dc *DownloadedContent) Delete() {
 // DownloadContent will download a url to a local file. It's efficient because it will
 // write as it downloads and not load the whole file into memory.
 func DownloadContent(url *url.URL, resp *http.Response, o observe.Observatory, parentSpan opentracing.Span) *DownloadedContent {
+	return DownloadContentToDir(os.TempDir(), url, resp, o, parentSpan)
+}
+
+// DownloadContentToDir will download a url to a local file in destDir, see DownloadContent.
+func DownloadContentToDir(destDir string, url *url.URL, resp *http.Response, o observe.Observatory, parentSpan opentracing.Span) *DownloadedContent {
 	span := o.StartChildTrace("DownloadContent", parentSpan)
 	defer span.Finish()
 
-	destFile, err := ioutil.TempFile(os.TempDir(), "harvester-dl-")
-	span.LogFields(log.String("downloadedAsName", destFile.Name()))
-
 	result := new(DownloadedContent)
 	result.URL = url
+	destFile, err := ioutil.TempFile(destDir, "harvester-dl-")
 	if err != nil {
+		resp.Body.Close()
 		result.DownloadError = err
 		opentrext.Error.Set(span, true)
 		span.LogFields(log.Error(err))
 		return result
 	}
+	span.LogFields(log.String("downloadedAsName", destFile.Name()))
 
 	defer destFile.Close()
 	defer resp.Body.Close()
 	result.DestPath = destFile.Name()
-	_, err = io.Copy(destFile, resp.Body)
+	digest := sha256.New()
+	result.Size, err = io.Copy(io.MultiWriter(destFile, digest), resp.Body)
 	if err != nil {
 		result.DownloadError = err
 		opentrext.Error.Set(span, true)
@@ -65,6 +76,7 @@ func DownloadContent(url *url.URL, resp *http.Response, o observe.Observatory, p
 		return result
 	}
 	destFile.Close()
+	result.Digest = hex.EncodeToString(digest.Sum(nil))
 
 	// Open the just-downloaded file again since it was closed already
 	file, err := os.Open(result.DestPath)
@@ -80,17 +92,26 @@ func DownloadContent(url *url.URL, resp *http.Response, o observe.Observatory, p
 	file.Read(head)
 	file.Close()
 
-	result.FileType, result.FileTypeError = filetype.Match(head)
-	if result.FileTypeError == nil {
-		// change the extension so that it matches the file type we found
-		currentPath := result.DestPath
-		currentExtension := path.Ext(currentPath)
-		newPath := currentPath[0:len(currentPath)-len(currentExtension)] + "." + result.FileType.Extension
-		os.Rename(currentPath, newPath)
-		result.DestPath = newPath
-		span.LogFields(log.String("FinalDestName", newPath))
+	result.FileType, err = filetype.Match(head)
+	if err != nil {
+		result.FileTypeError = &ParseError{URL: urlText(url), Subject: "file type", Err: err}
+		span.LogFields(log.Error(result.FileTypeError))
+		return result
 	}
 
+	// change the extension so that it matches the file type we found
+	currentPath := result.DestPath
+	currentExtension := path.Ext(currentPath)
+	newPath := currentPath[0:len(currentPath)-len(currentExtension)] + "." + result.FileType.Extension
+	if err := os.Rename(currentPath, newPath); err != nil {
+		result.DownloadError = err
+		opentrext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+		return result
+	}
+	result.DestPath = newPath
+	span.LogFields(log.String("FinalDestName", newPath))
+
 	return result
 }
 
@@ -101,22 +122,105 @@ type HarvestedResourceContent struct {
 	mediaType                    string
 	mediaTypeParams              map[string]string
 	mediaTypeError               error
+	contentEncoding              string // the Content-Encoding (e.g. gzip or br) the body was decompressed from
+	contentEncodingError         error
+	truncation                   error // a *TooLargeError if only part of the HTML was inspected
 	htmlParseError               error
 	isHTMLRedirect               bool
 	metaRefreshTagContentURLText string            // if IsHTMLRedirect is true, then this is the value after url= in something like <meta http-equiv='refresh' content='delay;url='>
-	metaPropertyTags             map[string]string // if IsHTML() is true, a collection of all meta data like <meta property="og:site_name" content="Netspective" /> or <meta name="twitter:title" content="text" />
+	metaPropertyTags             map[string]string // if IsHTML() is true, the first value of all meta data like <meta property="og:site_name" content="Netspective" /> or <meta name="twitter:title" content="text" />
+	metaTags                     []MetaTag         // if IsHTML() is true, every meta tag in document order, including repeated names like og:image
+	titleElementText             string            // if IsHTML() is true, the text inside the <title> element of <head>
+	bodyTextSample               string            // if IsHTML() is true, the beginning of the visible text of <body>
+	htmlLang                     string            // if IsHTML() is true, the lang attribute of the <html> element
+	charset                      string            // if IsHTML() is true, the character encoding the content was transcoded to UTF-8 from
+	charsetSource                CharsetSource     // if IsHTML() is true, how the character encoding was determined
+	embeddedSources              []string          // if IsHTML() is true, the src of every <script>, <iframe> and <frame>
+	deadPage                     *DeadPageDetection
+	article                      *Article
+	structuredData               *StructuredData
+	links                        PageLinks
+	retainedHTML                 *retainedHTML
+	pdf                          *PDFDocument
+	pdfError                     error
 	downloaded                   *DownloadedContent
 }
 
+// ContentDetectionOptions controls how destination content is inspected
+type ContentDetectionOptions struct {
+	// DownloadDir is where non-HTML content is downloaded to; when empty, os.TempDir() is used
+	DownloadDir string
+
+	// BlobStore, when not nil, stores downloads under their SHA-256 digest, deduplicating identical files
+	BlobStore *BlobStore
+
+	// ExtractArticle runs a readability algorithm on HTML content to extract its main content, see Article
+	ExtractArticle bool
+
+	// MaxHTMLBytes is how much decompressed HTML is inspected, the rest is ignored and the content is marked
+	// as truncated (see IsTruncated); when zero, DefaultMaxHTMLBytes is used and when negative there's no limit
+	MaxHTMLBytes int64
+
+	// HeadOnly stops reading HTML after </head> when only meta data is needed; it's ignored when ExtractArticle
+	// is set, and leaves nothing for checks that look at the <body> (such as dead page detection)
+	HeadOnly bool
+
+	// RetainHTML keeps the HTML that was read available through HarvestedResourceContent.HTML; up to
+	// RetainHTMLMemoryBytes are kept in memory (DefaultRetainedHTMLMemoryBytes when zero) and larger pages
+	// are spilled to a file in DownloadDir
+	RetainHTML            bool
+	RetainHTMLMemoryBytes int64
+
+	// retainedHTMLBudget, when not nil, is shared with other pages instead of RetainHTMLMemoryBytes
+	retainedHTMLBudget *htmlMemoryBudget
+
+	// PDFInspector, when not nil, reads the metadata and text of downloaded PDFs, see HarvestedResourceContent.PDF
+	PDFInspector *PDFInspector
+
+	// resolveDownloadDir, when DownloadDir is empty, is called to get the directory the first time a file is written
+	resolveDownloadDir func() (string, error)
+}
+
+// downloadDir returns the directory files are written to, resolving it only once it's needed; if it
+// can't be resolved the system's temporary directory is used
+func (o ContentDetectionOptions) downloadDir() string {
+	dir := o.DownloadDir
+	if len(dir) == 0 && o.resolveDownloadDir != nil {
+		dir, _ = o.resolveDownloadDir()
+	}
+	if len(dir) == 0 {
+		dir = os.TempDir()
+	}
+	return dir
+}
+
 // DetectHarvestedResourceContent will figure out what kind of destination content we're dealing with
 func DetectHarvestedResourceContent(url *url.URL, resp *http.Response, o observe.Observatory, parentSpan opentracing.Span) *HarvestedResourceContent {
+	return DetectHarvestedResourceContentWithOptions(url, resp, o, parentSpan, ContentDetectionOptions{})
+}
+
+// DetectHarvestedResourceContentWithOptions will figure out what kind of destination content we're dealing with
+func DetectHarvestedResourceContentWithOptions(url *url.URL, resp *http.Response, o observe.Observatory, parentSpan opentracing.Span, options ContentDetectionOptions) *HarvestedResourceContent {
 	result := new(HarvestedResourceContent)
 	result.metaPropertyTags = make(map[string]string)
 	result.url = url
+	encoding, encodingErr := decodeContentEncoding(resp)
+	result.contentEncoding = encoding
+	if encodingErr != nil {
+		result.contentEncodingError = &ParseError{URL: urlText(url), Subject: "content encoding", Err: encodingErr}
+		resp.Body.Close()
+		span := o.StartChildTrace("detectResourceContent", parentSpan)
+		defer span.Finish()
+		opentrext.Error.Set(span, true)
+		span.LogFields(log.Error(result.contentEncodingError))
+		return result
+	}
 	result.contentType = resp.Header.Get("Content-Type")
 	if len(result.contentType) > 0 {
-		result.mediaType, result.mediaTypeParams, result.mediaTypeError = mime.ParseMediaType(result.contentType)
-		if result.mediaTypeError != nil {
+		var err error
+		result.mediaType, result.mediaTypeParams, err = mime.ParseMediaType(result.contentType)
+		if err != nil {
+			result.mediaTypeError = &ParseError{URL: urlText(url), Subject: "media type", Err: err}
 			span := o.StartChildTrace("detectResourceContent", parentSpan)
 			defer span.Finish()
 			opentrext.Error.Set(span, true)
@@ -126,14 +230,65 @@ func DetectHarvestedResourceContent(url *url.URL, resp *http.Response, o observe
 			return result
 		}
 		if result.IsHTML() {
-			result.parsePageMetaData(url, resp, o, parentSpan)
+			maxBytes := options.MaxHTMLBytes
+			if maxBytes == 0 {
+				maxBytes = DefaultMaxHTMLBytes
+			}
+			// the HTML is retained as it was served, before it's limited and transcoded, so that it
+			// still matches its <meta charset>
+			var retainer *htmlRetainer
+			if options.RetainHTML {
+				budget := options.retainedHTMLBudget
+				if budget == nil {
+					budget = newHTMLMemoryBudget(options.RetainHTMLMemoryBytes)
+				}
+				retainer = newHTMLRetainer(options.downloadDir, budget)
+				body := resp.Body
+				resp.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(body, retainer), body}
+			}
+			headOnly := options.HeadOnly && !options.ExtractArticle
+			limiter := limitHTMLBody(resp, maxBytes, headOnly)
+			result.decodeHTMLBody(resp)
+			// keep a copy of the page as it's parsed if the article is extracted from it
+			var pageHTML strings.Builder
+			if options.ExtractArticle {
+				body := resp.Body
+				resp.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(body, &pageHTML), body}
+			}
+			parseErr := result.parsePageMetaData(url, resp, o, parentSpan)
+			if options.ExtractArticle && parseErr == nil {
+				result.extractArticle(url, pageHTML.String(), o, parentSpan)
+			}
+			if limiter != nil && limiter.exceeded {
+				result.truncation = &TooLargeError{URL: urlText(url), Limit: maxBytes}
+			}
+			if retainer != nil {
+				result.retainedHTML = retainer.finish(result.truncation != nil || headOnly)
+			}
 			return result
 		}
 	}
 
 	// If we get to here it means that we need to download the content to inspect it.
 	// We download it first because it's possible we want to retain it for later use.
-	result.downloaded = DownloadContent(url, resp, o, parentSpan)
+	result.downloaded = DownloadContentToDir(options.downloadDir(), url, resp, o, parentSpan)
+	if options.PDFInspector != nil && result.downloaded.DownloadError == nil && result.IsPDF() {
+		result.inspectPDF(options.PDFInspector, o, parentSpan)
+	}
+	if options.BlobStore != nil && result.downloaded.DownloadError == nil {
+		if err := options.BlobStore.Store(result.downloaded, result.contentType); err != nil {
+			span := o.StartChildTrace("storeDownloadedContent", parentSpan)
+			defer span.Finish()
+			opentrext.Error.Set(span, true)
+			span.LogFields(log.Error(err))
+		}
+	}
 	return result
 }
 
@@ -149,7 +304,7 @@ func (c *HarvestedResourceContent) parsePageMetaData(url *url.URL, resp *http.Re
 	if parseError != nil {
 		opentrext.Error.Set(span, true)
 		span.LogFields(log.Error(parseError))
-		c.htmlParseError = parseError
+		c.htmlParseError = &ParseError{URL: urlText(url), Subject: "HTML", Err: parseError}
 		return parseError
 	}
 	defer resp.Body.Close()
@@ -160,6 +315,25 @@ func (c *HarvestedResourceContent) parsePageMetaData(url *url.URL, resp *http.Re
 		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "head") {
 			inHead = true
 		}
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "html") && len(c.htmlLang) == 0 {
+			for _, attr := range n.Attr {
+				if strings.EqualFold(attr.Key, "lang") || strings.EqualFold(attr.Key, "xml:lang") {
+					c.htmlLang = strings.TrimSpace(attr.Val)
+				}
+			}
+		}
+		if inHead && n.Type == html.ElementNode && strings.EqualFold(n.Data, "title") && len(c.titleElementText) == 0 {
+			if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				c.titleElementText = strings.TrimSpace(n.FirstChild.Data)
+			}
+		}
+		if n.Type == html.ElementNode && (strings.EqualFold(n.Data, "script") || strings.EqualFold(n.Data, "iframe") || strings.EqualFold(n.Data, "frame")) {
+			for _, attr := range n.Attr {
+				if strings.EqualFold(attr.Key, "src") && len(attr.Val) > 0 {
+					c.embeddedSources = append(c.embeddedSources, attr.Val)
+				}
+			}
+		}
 		if inHead && n.Type == html.ElementNode && strings.EqualFold(n.Data, "meta") {
 			for _, attr := range n.Attr {
 				if strings.EqualFold(attr.Key, "http-equiv") && strings.EqualFold(strings.TrimSpace(attr.Val), "refresh") {
@@ -181,7 +355,7 @@ func (c *HarvestedResourceContent) parsePageMetaData(url *url.URL, resp *http.Re
 					propertyName := attr.Val
 					for _, attr := range n.Attr {
 						if strings.EqualFold(attr.Key, "content") {
-							c.metaPropertyTags[propertyName] = attr.Val
+							c.addMetaTag(propertyName, attr.Val)
 						}
 					}
 				}
@@ -192,9 +366,66 @@ func (c *HarvestedResourceContent) parsePageMetaData(url *url.URL, resp *http.Re
 		}
 	}
 	f(doc)
+	c.bodyTextSample = htmlBodyText(doc, maxBodyTextSampleLength)
+	c.structuredData = parseStructuredData(doc)
+	c.links = parsePageLinks(doc, url)
 	return nil
 }
 
+func (c *HarvestedResourceContent) extractArticle(url *url.URL, pageHTML string, o observe.Observatory, parentSpan opentracing.Span) {
+	span := o.StartChildTrace("extractArticle", parentSpan)
+	defer span.Finish()
+
+	article, err := extractArticle(url, pageHTML, c)
+	if err != nil {
+		opentrext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+		return
+	}
+	c.article = article
+	span.LogFields(log.Int("textLength", len(article.Text)))
+}
+
+// maxBodyTextSampleLength is how much of the visible body text is kept for content heuristics
+const maxBodyTextSampleLength = 4096
+
+// htmlBodyText returns up to limit bytes of the visible text inside <body>, with whitespace collapsed
+func htmlBodyText(doc *html.Node, limit int) string {
+	var text strings.Builder
+	var f func(*html.Node, bool)
+	f = func(n *html.Node, inBody bool) {
+		if text.Len() >= limit {
+			return
+		}
+		if n.Type == html.ElementNode {
+			switch strings.ToLower(n.Data) {
+			case "script", "style", "noscript", "template":
+				return
+			case "body":
+				inBody = true
+			}
+		}
+		if inBody && n.Type == html.TextNode {
+			if words := strings.Fields(n.Data); len(words) > 0 {
+				if text.Len() > 0 {
+					text.WriteByte(' ')
+				}
+				text.WriteString(strings.Join(words, " "))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c, inBody)
+		}
+	}
+	f(doc, false)
+
+	result := text.String()
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
 // IsValid returns true if this there are no errors
 func (c HarvestedResourceContent) IsValid() bool {
 	if c.mediaTypeError != nil {
@@ -213,23 +444,130 @@ func (c HarvestedResourceContent) IsValid() bool {
 	return true
 }
 
+// Err returns the first error encountered while inspecting or downloading the content, or nil; the
+// error may be inspected with errors.As (e.g. *ParseError)
+func (c HarvestedResourceContent) Err() error {
+	if c.contentEncodingError != nil {
+		return c.contentEncodingError
+	}
+	if c.mediaTypeError != nil {
+		return c.mediaTypeError
+	}
+	if c.htmlParseError != nil {
+		return c.htmlParseError
+	}
+	if c.downloaded != nil {
+		if c.downloaded.DownloadError != nil {
+			return c.downloaded.DownloadError
+		}
+		return c.downloaded.FileTypeError
+	}
+	return nil
+}
+
+// IsTruncated returns true and a *TooLargeError if the HTML was larger than the inspection limit (see
+// ContentDetectionOptions.MaxHTMLBytes), in which case only its beginning was parsed
+func (c HarvestedResourceContent) IsTruncated() (bool, error) {
+	return c.truncation != nil, c.truncation
+}
+
+// ContentEncoding returns the Content-Encoding (e.g. gzip, deflate or br) the content was decompressed
+// from, or an empty string if it wasn't compressed
+func (c HarvestedResourceContent) ContentEncoding() string {
+	return c.contentEncoding
+}
+
 // IsHTML returns true if this is HTML content
 func (c HarvestedResourceContent) IsHTML() bool {
 	return c.mediaType == "text/html"
 }
 
-// GetOpenGraphMetaTag returns the value and true if og:key was found
+// IsPDF returns true if the content is a PDF, going by its Content-Type or else the type of the downloaded file
+func (c HarvestedResourceContent) IsPDF() bool {
+	if c.mediaType == "application/pdf" {
+		return true
+	}
+	return c.downloaded != nil && c.downloaded.FileType.MIME.Value == "application/pdf"
+}
+
+// GetOpenGraphMetaTag returns the value and true if og:key was found; if the page has several, the
+// first one is returned, as the Open Graph protocol gives the first value precedence (see GetOpenGraphMetaTagValues)
 func (c HarvestedResourceContent) GetOpenGraphMetaTag(key string) (string, bool) {
 	result, ok := c.metaPropertyTags["og:"+key]
 	return result, ok
 }
 
-// GetTwitterMetaTag returns the value and true if og:key was found
+// GetTwitterMetaTag returns the value and true if twitter:key was found; if the page has several, the
+// first one is returned, like GetOpenGraphMetaTag (see GetMetaTagValues)
 func (c HarvestedResourceContent) GetTwitterMetaTag(key string) (string, bool) {
 	result, ok := c.metaPropertyTags["twitter:"+key]
 	return result, ok
 }
 
+// GetMetaTag returns the value and true if a meta tag with the given property or name was found; if
+// the page has several, the first one is returned (see GetMetaTagValues)
+func (c HarvestedResourceContent) GetMetaTag(key string) (string, bool) {
+	result, ok := c.metaPropertyTags[key]
+	return result, ok
+}
+
+// GetTitleElement returns the text of the <title> element and true if it was found
+func (c HarvestedResourceContent) GetTitleElement() (string, bool) {
+	return c.titleElementText, len(c.titleElementText) > 0
+}
+
+// Charset returns the character encoding of HTML content (e.g. windows-1251), which was transcoded to
+// UTF-8 before it was parsed, and how the encoding was determined
+func (c HarvestedResourceContent) Charset() (string, CharsetSource) {
+	return c.charset, c.charsetSource
+}
+
+// ContentType returns the value of the Content-Type header the content was served with
+func (c HarvestedResourceContent) ContentType() string {
+	return c.contentType
+}
+
+// MediaType returns the media type (e.g. text/html) parsed from the Content-Type header
+func (c HarvestedResourceContent) MediaType() string {
+	return c.mediaType
+}
+
+// MetaTags returns a copy of all the meta tags found in the content, keyed by property or name; only the
+// first value of repeated names is included (see OrderedMetaTags)
+func (c HarvestedResourceContent) MetaTags() map[string]string {
+	result := make(map[string]string, len(c.metaPropertyTags))
+	for key, value := range c.metaPropertyTags {
+		result[key] = value
+	}
+	return result
+}
+
+// Downloaded returns the downloaded content, or nil if the content did not need to be downloaded
+func (c HarvestedResourceContent) Downloaded() *DownloadedContent {
+	return c.downloaded
+}
+
+// StructuredData returns the JSON-LD, microdata and RDFa items found in HTML content, or nil if the
+// content isn't HTML
+func (c HarvestedResourceContent) StructuredData() *StructuredData {
+	return c.structuredData
+}
+
+// Links returns the <link> elements (icons, feeds, alternates, etc.) of HTML content, with absolute URLs
+func (c HarvestedResourceContent) Links() PageLinks {
+	return c.links
+}
+
+// Article returns the main content of the page, or nil if it wasn't extracted (see ContentDetectionOptions)
+func (c HarvestedResourceContent) Article() *Article {
+	return c.article
+}
+
+// DeadPageDetection returns what the harvester's DeadPageDetector found, or nil if it wasn't run
+func (c HarvestedResourceContent) DeadPageDetection() *DeadPageDetection {
+	return c.deadPage
+}
+
 // WasDownloaded returns true if content was downloaded for inspection
 func (c HarvestedResourceContent) WasDownloaded() bool {
 	return c.downloaded != nil
@@ -247,19 +585,59 @@ func (c HarvestedResourceContent) IsHTMLRedirect() (bool, string) {
 type HarvestedResource struct {
 	// TODO consider adding source information (e.g. tweet, e-mail, etc.) and embed style (e.g. text, HTML <a> tag, etc.)
 	harvestedOn     time.Time
+	status          ResourceStatus
+	statusHistory   []ResourceStatusChange
 	origURLtext     string
 	origResource    *HarvestedResource
 	isURLValid      bool
 	isDestValid     bool
 	httpStatusCode  int
+	httpStatus      string
+	httpHeaders     http.Header
+	isGone          bool
 	isURLIgnored    bool
 	ignoreReason    string
+	err             error
 	isURLCleaned    bool
 	isURLAttachment bool
 	resolvedURL     *url.URL
 	cleanedURL      *url.URL
 	finalURL        *url.URL
 	resourceContent *HarvestedResourceContent
+	oEmbed          *OEmbed
+}
+
+// HarvestedOn returns the time the resource was harvested
+func (r *HarvestedResource) HarvestedOn() time.Time {
+	return r.harvestedOn
+}
+
+// HTTPStatusCode returns the status code of the response, or 0 if no response was received
+func (r *HarvestedResource) HTTPStatusCode() int {
+	return r.httpStatusCode
+}
+
+// HTTPStatus returns the full status line of the response (e.g. "200 OK"), or "" if no response was received
+func (r *HarvestedResource) HTTPStatus() string {
+	return r.httpStatus
+}
+
+// HTTPHeaders returns the headers of the (final, after following redirects) response, or nil if no
+// response was received
+func (r *HarvestedResource) HTTPHeaders() http.Header {
+	return r.httpHeaders
+}
+
+// IsGone returns true if the resource is permanently missing: it responded with one of the status
+// policy's gone status codes or its content looked like a "page not found" page
+func (r *HarvestedResource) IsGone() bool {
+	return r.isGone
+}
+
+// OEmbed returns the oEmbed response for the resource, or nil if the harvester has no OEmbedClient or
+// no allowed provider serves the resource
+func (r *HarvestedResource) OEmbed() *OEmbed {
+	return r.oEmbed
 }
 
 // OriginalURLText returns the URL as it was discovered, with no alterations
@@ -281,10 +659,24 @@ func (r *HarvestedResource) IsValid() (bool, bool) {
 
 // IsIgnored indicates whether the URL should be ignored based on harvesting rules.
 // Discovered URLs may be ignored for a variety of reasons using a list of Regexps.
+// URLs which could not be retrieved are not ignored, see Status and Err instead.
 func (r *HarvestedResource) IsIgnored() (bool, string) {
 	return r.isURLIgnored, r.ignoreReason
 }
 
+// Err returns why the resource could not be harvested or was ignored, or else the error encountered
+// while inspecting its content; nil means the resource was harvested successfully. Callers may use
+// errors.As to inspect the error (e.g. *HTTPStatusError, *DNSError or *IgnoredByRuleError).
+func (r *HarvestedResource) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.resourceContent != nil {
+		return r.resourceContent.Err()
+	}
+	return nil
+}
+
 // IsCleaned indicates whether URL query parameters were removed and the new "cleaned" URL
 func (r *HarvestedResource) IsCleaned() (bool, *url.URL) {
 	return r.isURLCleaned, r.cleanedURL
@@ -356,19 +748,29 @@ func harvestResource(h *ContentHarvester, parentSpan opentracing.Span, origURLte
 	result := new(HarvestedResource)
 	result.origURLtext = origURLtext
 	result.harvestedOn = time.Now()
+	result.transition(ResourceDiscovered, "")
 
 	// Use the standard Go HTTP library method to retrieve the content; the
 	// default will automatically follow redirects (e.g. HTTP redirects)
-	resp, err := http.Get(origURLtext)
+	var resp *http.Response
+	err := validateURLText(origURLtext)
+	if err == nil {
+		var req *http.Request
+		if req, err = newHarvestRequest(origURLtext); err == nil {
+			resp, err = http.DefaultClient.Do(req)
+		}
+		if err != nil {
+			err = classifyRequestError(origURLtext, err)
+		}
+	}
 	result.isURLValid = err == nil
 	if result.isURLValid == false {
 		result.isDestValid = false
-		result.isURLIgnored = true
-		result.ignoreReason = fmt.Sprintf("Invalid URL '%s'", origURLtext)
+		result.err = err
+		result.transition(ResourceUnreachable, err.Error())
 		span.LogFields(
 			log.Bool("isDestValid", result.isDestValid),
-			log.Bool("isURLIgnored", result.isURLIgnored),
-			log.String("ignoreReason", result.ignoreReason),
+			log.String("status", result.status.String()),
 			log.Error(err),
 		)
 		opentrext.Error.Set(span, true)
@@ -376,14 +778,18 @@ func harvestResource(h *ContentHarvester, parentSpan opentracing.Span, origURLte
 	}
 
 	result.httpStatusCode = resp.StatusCode
-	if result.httpStatusCode != 200 {
+	result.httpStatus = resp.Status
+	result.httpHeaders = resp.Header
+	if !h.statusPolicy.IsAccepted(result.httpStatusCode) {
 		result.isDestValid = false
-		result.isURLIgnored = true
-		result.ignoreReason = fmt.Sprintf("Invalid HTTP Status Code %d", resp.StatusCode)
+		result.isGone = h.statusPolicy.IsGone(result.httpStatusCode)
+		result.err = &HTTPStatusError{URL: origURLtext, StatusCode: resp.StatusCode, Gone: result.isGone}
+		result.transition(ResourceHTTPError, result.err.Error())
+		resp.Body.Close()
 		span.LogFields(
 			log.Bool("isDestValid", result.isDestValid),
-			log.Bool("isURLIgnored", result.isURLIgnored),
-			log.String("ignoreReason", result.ignoreReason),
+			log.String("status", result.status.String()),
+			log.Error(result.err),
 		)
 		opentrext.Error.Set(span, true)
 		opentrext.HTTPStatusCode.Set(span, uint16(result.httpStatusCode))
@@ -397,10 +803,14 @@ func harvestResource(h *ContentHarvester, parentSpan opentracing.Span, origURLte
 		result.isDestValid = true
 		result.isURLIgnored = true
 		result.ignoreReason = ignoreReason
+		result.err = &IgnoredByRuleError{URL: result.resolvedURL.String(), Reason: ignoreReason}
+		result.transition(ResourceIgnoredByRule, ignoreReason)
+		resp.Body.Close()
 		span.LogFields(
 			log.Bool("isDestValid", result.isDestValid),
 			log.Bool("isURLIgnored", result.isURLIgnored),
 			log.String("ignoreReason", result.ignoreReason),
+			log.String("status", result.status.String()),
 		)
 		return result
 	}
@@ -417,6 +827,29 @@ func harvestResource(h *ContentHarvester, parentSpan opentracing.Span, origURLte
 	}
 
 	result.resourceContent = h.detectResourceContent(result.finalURL, resp, h.observatory, span)
+	if contentErr := result.resourceContent.Err(); contentErr != nil {
+		result.transition(ResourceContentError, contentErr.Error())
+	} else if detection := h.detectDeadPage(result.resourceContent, span); detection.IsDead() {
+		result.isDestValid = false
+		result.isGone = true
+		if detection.Kind == ParkedDomainPage {
+			result.err = &ParkedDomainError{URL: origURLtext, Confidence: detection.Confidence, Signals: detection.Signals}
+		} else {
+			reason := fmt.Sprintf("confidence %.2f, %s", detection.Confidence, strings.Join(detection.Signals, "; "))
+			result.err = &Soft404Error{URL: origURLtext, StatusCode: resp.StatusCode, Reason: reason}
+		}
+		result.transition(ResourceHTTPError, result.err.Error())
+		span.LogFields(log.Bool("isDestValid", result.isDestValid), log.Error(result.err))
+	} else if isSoft404, reason := h.isSoft404(result.resourceContent); isSoft404 {
+		result.isDestValid = false
+		result.isGone = true
+		result.err = &Soft404Error{URL: origURLtext, StatusCode: resp.StatusCode, Reason: reason}
+		result.transition(ResourceHTTPError, result.err.Error())
+		span.LogFields(log.Bool("isDestValid", result.isDestValid), log.Error(result.err))
+	} else {
+		result.oEmbed = h.fetchOEmbed(result.finalURL, result.resourceContent, span)
+		result.transition(ResourceResolved, "")
+	}
 	span.LogFields(log.Object("result", result))
 
 	// TODO once the URL is cleaned, double-check the cleaned URL to see if it's a valid destination; if not, revert to non-cleaned version
@@ -0,0 +1,173 @@
+package harvester
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// DefaultRetainedHTMLMemoryBytes is how much retained HTML, of all pages together, is kept in memory
+// before further HTML is spilled to files
+const DefaultRetainedHTMLMemoryBytes int64 = 1 << 20
+
+// ErrHTMLNotRetained is returned by HarvestedResourceContent.HTML when the HTML wasn't retained
+var ErrHTMLNotRetained = errors.New("HTML was not retained, see ContentDetectionOptions.RetainHTML")
+
+// retainedHTML is the HTML of a page kept after it was parsed, either in memory or in a file
+type retainedHTML struct {
+	data      []byte
+	budget    *htmlMemoryBudget // the budget data was taken from
+	path      string            // set if the HTML was spilled to a file
+	size      int64
+	truncated bool // set if only the beginning of the page was read
+	err       error
+}
+
+// htmlMemoryBudget is how much retained HTML may still be kept in memory; a harvester shares one budget
+// between all of its pages
+type htmlMemoryBudget struct {
+	mutex     sync.Mutex
+	remaining int64
+}
+
+func newHTMLMemoryBudget(maxMemory int64) *htmlMemoryBudget {
+	if maxMemory == 0 {
+		maxMemory = DefaultRetainedHTMLMemoryBytes
+	}
+	return &htmlMemoryBudget{remaining: maxMemory}
+}
+
+// reserve takes n bytes from the budget, or returns false if there aren't enough left
+func (b *htmlMemoryBudget) reserve(n int64) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if n > b.remaining {
+		return false
+	}
+	b.remaining -= n
+	return true
+}
+
+// release returns n bytes to the budget
+func (b *htmlMemoryBudget) release(n int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.remaining += n
+}
+
+// htmlRetainer collects HTML as it's read, in memory while the budget allows and then in a temporary
+// file in the directory returned by dir
+type htmlRetainer struct {
+	dir    func() string
+	budget *htmlMemoryBudget
+	buffer bytes.Buffer
+	file   *os.File
+	size   int64
+	err    error
+}
+
+func newHTMLRetainer(dir func() string, budget *htmlMemoryBudget) *htmlRetainer {
+	return &htmlRetainer{dir: dir, budget: budget}
+}
+
+// Write never fails so that a retention problem doesn't stop the page from being parsed; the error is
+// reported by HarvestedResourceContent.HTML instead
+func (r *htmlRetainer) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return len(p), nil
+	}
+	r.size += int64(len(p))
+	if r.file == nil && !r.budget.reserve(int64(len(p))) {
+		// the memory the buffer took is given back whether or not it could be spilled
+		buffered := int64(r.buffer.Len())
+		r.file, r.err = ioutil.TempFile(r.dir(), "harvester-html-")
+		if r.err == nil {
+			_, r.err = r.buffer.WriteTo(r.file)
+		}
+		r.buffer = bytes.Buffer{}
+		r.budget.release(buffered)
+		if r.err != nil {
+			return len(p), nil
+		}
+	}
+	if r.file != nil {
+		_, r.err = r.file.Write(p)
+		return len(p), nil
+	}
+	r.buffer.Write(p)
+	return len(p), nil
+}
+
+func (r *htmlRetainer) finish(truncated bool) *retainedHTML {
+	result := &retainedHTML{size: r.size, truncated: truncated, err: r.err}
+	if r.file == nil {
+		result.data = r.buffer.Bytes()
+		result.budget = r.budget
+		return result
+	}
+	result.path = r.file.Name()
+	if err := r.file.Close(); err != nil && result.err == nil {
+		result.err = err
+	}
+	if result.err != nil {
+		os.Remove(result.path)
+		result.path = ""
+	}
+	return result
+}
+
+// remove gives the memory the HTML took back to the budget or deletes the file it was spilled to
+func (h *retainedHTML) remove() error {
+	if h == nil {
+		return nil
+	}
+	if h.budget != nil {
+		h.budget.release(int64(len(h.data)))
+		h.budget = nil
+	}
+	h.data = nil
+	h.err = ErrHTMLNotRetained
+	if len(h.path) == 0 {
+		return nil
+	}
+	err := os.Remove(h.path)
+	h.path = ""
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// HTML returns the page's HTML as it was served (decompressed but still in the encoding reported by Charset)
+// so that it can be processed again without another request; the caller must close it. Only what was read
+// is retained, see IsRetainedHTMLTruncated. ErrHTMLNotRetained is returned unless the content was detected
+// with ContentDetectionOptions.RetainHTML, or once the harvester is cleaned up or closed.
+func (c HarvestedResourceContent) HTML() (io.ReadCloser, error) {
+	h := c.retainedHTML
+	switch {
+	case h == nil:
+		return nil, ErrHTMLNotRetained
+	case h.err != nil:
+		return nil, h.err
+	case len(h.path) > 0:
+		return os.Open(h.path)
+	}
+	return ioutil.NopCloser(bytes.NewReader(h.data)), nil
+}
+
+// RetainedHTMLSize returns the number of bytes of HTML that were retained, see HTML
+func (c HarvestedResourceContent) RetainedHTMLSize() int64 {
+	if c.retainedHTML == nil {
+		return 0
+	}
+	return c.retainedHTML.size
+}
+
+// IsRetainedHTMLTruncated returns true if the retained HTML is only the beginning of the page, because the
+// page was larger than the inspection limit (see IsTruncated) or only its <head> was read
+func (c HarvestedResourceContent) IsRetainedHTMLTruncated() bool {
+	return c.retainedHTML != nil && c.retainedHTML.truncated
+}
@@ -0,0 +1,149 @@
+package harvester
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var retainHTMLTestFixtures = testFixtures{pages: map[string]string{"/article": articleTestPage}}
+
+type RetainHTMLSuite struct {
+	harvesterSuite
+}
+
+func (suite *RetainHTMLSuite) SetupSuite() {
+	suite.setupSuite(retainHTMLTestFixtures.handler())
+}
+
+func (suite *RetainHTMLSuite) harvest(ch *ContentHarvester, path string) *HarvestedResource {
+	harvested := ch.HarvestResources(fmt.Sprintf("Test page %s%s in a mock tweet", suite.server.URL, path), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return harvested.Resources[0]
+}
+
+func (suite *RetainHTMLSuite) readHTML(content *HarvestedResourceContent) string {
+	html, err := content.HTML()
+	suite.Require().NoError(err)
+	defer html.Close()
+	data, err := ioutil.ReadAll(html)
+	suite.Require().NoError(err)
+	return string(data)
+}
+
+func (suite *RetainHTMLSuite) TestInMemory() {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{RetainHTML: true, ExtractArticles: true})
+	defer ch.Close()
+
+	content := suite.harvest(ch, "/article").ResourceContent()
+	html := suite.readHTML(content)
+	suite.Equal(articleTestPage, html)
+	suite.Equal(int64(len(html)), content.RetainedHTMLSize())
+	suite.Equal(html, suite.readHTML(content), "The HTML can be read more than once")
+	suite.NotNil(content.Article(), "Retaining HTML should not interfere with article extraction")
+	suite.Equal(0, len(ch.Downloads()))
+}
+
+func (suite *RetainHTMLSuite) TestSpilledToWorkDir() {
+	workDir, err := ioutil.TempDir("", "harvester-retain-")
+	suite.Require().NoError(err)
+	defer os.RemoveAll(workDir)
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{WorkDir: workDir, RetainHTML: true, RetainHTMLMemoryBytes: 64})
+
+	content := suite.harvest(ch, "/og").ResourceContent()
+	suite.Equal(commonTestPages["/og"], suite.readHTML(content))
+	files, _ := ioutil.ReadDir(workDir)
+	suite.Require().Equal(1, len(files))
+	suite.True(strings.HasPrefix(files[0].Name(), "harvester-html-"))
+
+	suite.NoError(ch.Cleanup())
+	files, _ = ioutil.ReadDir(workDir)
+	suite.Equal(0, len(files), "Close should delete retained HTML files")
+	_, err = content.HTML()
+	suite.Equal(ErrHTMLNotRetained, err)
+}
+
+func (suite *RetainHTMLSuite) TestNotRetained() {
+	ch := MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	defer ch.Close()
+	content := suite.harvest(ch, "/og").ResourceContent()
+	_, err := content.HTML()
+	suite.Equal(ErrHTMLNotRetained, err)
+	suite.Equal(int64(0), content.RetainedHTMLSize())
+}
+
+func (suite *RetainHTMLSuite) TestTruncation() {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{RetainHTML: true})
+	defer ch.Close()
+	suite.False(suite.harvest(ch, "/og").ResourceContent().IsRetainedHTMLTruncated())
+
+	ch = MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{RetainHTML: true, MaxHTMLBytes: 32})
+	defer ch.Close()
+	content := suite.harvest(ch, "/og").ResourceContent()
+	suite.True(content.IsRetainedHTMLTruncated())
+	suite.True(strings.HasPrefix(commonTestPages["/og"], suite.readHTML(content)))
+
+	ch = MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{RetainHTML: true, InspectHeadOnly: true})
+	defer ch.Close()
+	suite.True(suite.harvest(ch, "/article").ResourceContent().IsRetainedHTMLTruncated(), "Only the <head> was read")
+}
+
+func (suite *RetainHTMLSuite) TestMemoryIsSharedByPages() {
+	workDir, err := ioutil.TempDir("", "harvester-retain-")
+	suite.Require().NoError(err)
+	defer os.RemoveAll(workDir)
+	// enough memory for either page but not both
+	memory := int64(len(commonTestPages["/og"]) + len(articleTestPage) - 1)
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{WorkDir: workDir, RetainHTML: true, RetainHTMLMemoryBytes: memory})
+	defer ch.Close()
+
+	first := suite.harvest(ch, "/og").ResourceContent()
+	second := suite.harvest(ch, "/article").ResourceContent()
+	files, _ := ioutil.ReadDir(workDir)
+	suite.Equal(1, len(files), "The second page should be spilled once the memory is used up")
+	suite.Equal(commonTestPages["/og"], suite.readHTML(first))
+	suite.Equal(articleTestPage, suite.readHTML(second))
+}
+
+func (suite *RetainHTMLSuite) TestCleanupReleasesMemory() {
+	workDir, err := ioutil.TempDir("", "harvester-retain-")
+	suite.Require().NoError(err)
+	defer os.RemoveAll(workDir)
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{WorkDir: workDir, RetainHTML: true, RetainHTMLMemoryBytes: int64(len(articleTestPage))})
+	defer ch.Close()
+
+	first := suite.harvest(ch, "/article").ResourceContent()
+	suite.Equal(articleTestPage, suite.readHTML(first))
+	suite.NoError(ch.Cleanup())
+	_, err = first.HTML()
+	suite.Equal(ErrHTMLNotRetained, err)
+
+	second := suite.harvest(ch, "/article").ResourceContent()
+	files, _ := ioutil.ReadDir(workDir)
+	suite.Equal(0, len(files), "The memory of the first harvest should be available again after Cleanup")
+	suite.Equal(articleTestPage, suite.readHTML(second))
+}
+
+func (suite *RetainHTMLSuite) TestKeysDontReparseRetainedHTML() {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList,
+		ContentHarvesterOptions{RetainHTML: true})
+	defer ch.Close()
+	keys := CreateHarvestedResourceKeys(suite.harvest(ch, "/untitled"), func(random uint32, try int) bool { return false })
+	suite.True(keys.IsValid())
+	suite.False(keys.HasTitle())
+}
+
+func TestRetainHTMLSuite(t *testing.T) {
+	suite.Run(t, new(RetainHTMLSuite))
+}
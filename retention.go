@@ -0,0 +1,129 @@
+package harvester
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DownloadRetentionPolicy is a rule which decides whether downloaded content is kept when the
+// harvester that downloaded it is closed; downloads which aren't retained are deleted.
+type DownloadRetentionPolicy interface {
+	RetainDownload(dc *DownloadedContent) (bool, string)
+}
+
+// RetainDownloadsByMIMEType keeps downloads whose detected MIME type matches one of the entries;
+// entries ending with "/" (e.g. "image/") match all subtypes
+type RetainDownloadsByMIMEType []string
+
+// RetainDownload returns true if the download's MIME type matches
+func (l RetainDownloadsByMIMEType) RetainDownload(dc *DownloadedContent) (bool, string) {
+	mimeType := dc.FileType.MIME.Value
+	if len(mimeType) == 0 {
+		return false, ""
+	}
+	for _, entry := range l {
+		if mimeType == entry || (strings.HasSuffix(entry, "/") && strings.HasPrefix(mimeType, entry)) {
+			return true, fmt.Sprintf("Matched retention MIME type `%s`", entry)
+		}
+	}
+	return false, ""
+}
+
+// RetainDownloadsBySize keeps downloads whose size is at least MinBytes and, when MaxBytes is
+// greater than zero, at most MaxBytes
+type RetainDownloadsBySize struct {
+	MinBytes int64
+	MaxBytes int64
+}
+
+// RetainDownload returns true if the download's size is within the bounds
+func (r RetainDownloadsBySize) RetainDownload(dc *DownloadedContent) (bool, string) {
+	if dc.Size < r.MinBytes || (r.MaxBytes > 0 && dc.Size > r.MaxBytes) {
+		return false, ""
+	}
+	return true, fmt.Sprintf("Size %d within retention bounds [%d, %d]", dc.Size, r.MinBytes, r.MaxBytes)
+}
+
+// RetainDownloadsMatchingAll keeps downloads which all of the policies retain (e.g. PDFs smaller than 10MB)
+type RetainDownloadsMatchingAll []DownloadRetentionPolicy
+
+// RetainDownload returns true if every policy retains the download
+func (l RetainDownloadsMatchingAll) RetainDownload(dc *DownloadedContent) (bool, string) {
+	if len(l) == 0 {
+		return false, ""
+	}
+	reasons := make([]string, 0, len(l))
+	for _, policy := range l {
+		retain, reason := policy.RetainDownload(dc)
+		if !retain {
+			return false, ""
+		}
+		reasons = append(reasons, reason)
+	}
+	return true, strings.Join(reasons, " and ")
+}
+
+// RetainDownloadsMatchingAny keeps downloads which any of the policies retains
+type RetainDownloadsMatchingAny []DownloadRetentionPolicy
+
+// RetainDownload returns true if at least one policy retains the download
+func (l RetainDownloadsMatchingAny) RetainDownload(dc *DownloadedContent) (bool, string) {
+	for _, policy := range l {
+		if retain, reason := policy.RetainDownload(dc); retain {
+			return true, reason
+		}
+	}
+	return false, ""
+}
+
+// Retain marks the download so that it's not deleted when the harvester is closed
+func (dc *DownloadedContent) Retain() {
+	dc.retained = true
+}
+
+// IsRetained returns true if the download will not be deleted when the harvester is closed
+func (dc *DownloadedContent) IsRetained() bool {
+	return dc.retained
+}
+
+// MoveTo moves the downloaded file to destPath (creating its directory if necessary) and retains it
+func (dc *DownloadedContent) MoveTo(destPath string) error {
+	if len(dc.DestPath) == 0 {
+		return fmt.Errorf("download of %s has no file to move", urlText(dc.URL))
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(dc.DestPath, destPath); err != nil {
+		// rename doesn't work across file systems so fall back to copying
+		if err = copyFile(dc.DestPath, destPath); err != nil {
+			return err
+		}
+		os.Remove(dc.DestPath)
+	}
+	dc.DestPath = destPath
+	dc.retained = true
+	return nil
+}
+
+func copyFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dest, src); err != nil {
+		dest.Close()
+		os.Remove(destPath)
+		return err
+	}
+	return dest.Close()
+}
@@ -0,0 +1,139 @@
+package harvester
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// emptyTestPDF is a PDF without any pages, which the PDF reader can't read
+const emptyTestPDF = "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n"
+
+// retentionTestFixtures are downloaded by RetentionSuite; the two PDFs are identical
+var retentionTestFixtures = testFixtures{files: map[string][2]string{
+	"/doc.pdf":   {"application/pdf", emptyTestPDF},
+	"/copy.pdf":  {"application/pdf", emptyTestPDF},
+	"/image.png": {"image/png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00"},
+}}
+
+type RetentionSuite struct {
+	harvesterSuite
+}
+
+func (suite *RetentionSuite) SetupSuite() {
+	suite.setupSuite(retentionTestFixtures.handler())
+}
+
+func (suite *RetentionSuite) harvestFiles(options ContentHarvesterOptions) (*ContentHarvester, *DownloadedContent, *DownloadedContent) {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, options)
+	harvested := ch.HarvestResources(fmt.Sprintf("Files %[1]s/doc.pdf and %[1]s/image.png in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(2, len(harvested.Resources))
+	pdf := harvested.Resources[0].ResourceContent().Downloaded()
+	png := harvested.Resources[1].ResourceContent().Downloaded()
+	suite.Require().NotNil(pdf)
+	suite.Require().NotNil(png)
+	return ch, pdf, png
+}
+
+func (suite *RetentionSuite) TestCloseDeletesDownloads() {
+	ch, pdf, png := suite.harvestFiles(ContentHarvesterOptions{})
+	workDir, _ := ch.WorkDir()
+	suite.Equal(workDir, filepath.Dir(pdf.DestPath), "Downloads should be stored in the harvester's working directory")
+	suite.Equal(".pdf", filepath.Ext(pdf.DestPath))
+	suite.Equal(2, len(ch.Downloads()))
+
+	suite.NoError(ch.Cleanup())
+	_, err := os.Stat(pdf.DestPath)
+	suite.True(os.IsNotExist(err), "The PDF should have been deleted")
+	_, err = os.Stat(png.DestPath)
+	suite.True(os.IsNotExist(err), "The PNG should have been deleted")
+	_, err = os.Stat(workDir)
+	suite.True(os.IsNotExist(err), "The harvester's temporary working directory should be removed")
+}
+
+func (suite *RetentionSuite) TestWorkDirCreatedOnFirstDownload() {
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{})
+	defer ch.Close()
+	ch.HarvestResources(fmt.Sprintf("Page %s/og in a mock tweet", suite.server.URL), suite.span)
+	suite.Empty(ch.workDir, "Harvesting HTML should not create a working directory")
+
+	ch.HarvestResources(fmt.Sprintf("File %s/doc.pdf in a mock tweet", suite.server.URL), suite.span)
+	suite.NotEmpty(ch.workDir)
+	suite.Equal(ch.workDir, filepath.Dir(ch.Downloads()[0].DestPath))
+}
+
+func (suite *RetentionSuite) TestRetentionPolicyAndMoveTo() {
+	ch, pdf, png := suite.harvestFiles(ContentHarvesterOptions{
+		RetentionPolicy: RetainDownloadsMatchingAll{RetainDownloadsByMIMEType{"application/pdf"}, RetainDownloadsBySize{MaxBytes: 1024}},
+	})
+	suite.Equal(int64(len(retentionTestFixtures.files["/image.png"][1])), png.Size)
+
+	keepDir, _ := ioutil.TempDir("", "harvester-retention-test-")
+	defer os.RemoveAll(keepDir)
+	suite.NoError(png.MoveTo(filepath.Join(keepDir, "images", "kept.png")))
+	suite.True(png.IsRetained())
+
+	suite.NoError(ch.Cleanup())
+	suite.FileExists(pdf.DestPath, "The PDF should be kept by the retention policy")
+	suite.FileExists(filepath.Join(keepDir, "images", "kept.png"))
+	pdf.Delete()
+}
+
+func (suite *RetentionSuite) TestRetentionPolicies() {
+	dc := &DownloadedContent{Size: 2048}
+	dc.FileType.MIME.Value = "image/png"
+	retain, _ := RetainDownloadsByMIMEType{"image/"}.RetainDownload(dc)
+	suite.True(retain)
+	retain, _ = RetainDownloadsBySize{MinBytes: 4096}.RetainDownload(dc)
+	suite.False(retain)
+	retain, _ = RetainDownloadsMatchingAny{RetainDownloadsBySize{MinBytes: 4096}, RetainDownloadsByMIMEType{"image/png"}}.RetainDownload(dc)
+	suite.True(retain)
+}
+
+func (suite *RetentionSuite) TestBlobStoreRejectsInvalidDigests() {
+	blobs := MakeBlobStore(os.TempDir())
+	for _, digest := range []string{"", "a", "../etc/passwd", strings.Repeat("z", 64)} {
+		_, err := blobs.Path(digest, "pdf")
+		suite.Error(err, "%q should be rejected", digest)
+		_, err = blobs.MetadataPath(digest)
+		suite.Error(err, "%q should be rejected", digest)
+		_, err = blobs.Metadata(digest)
+		suite.Error(err, "%q should be rejected", digest)
+	}
+}
+
+func (suite *RetentionSuite) TestBlobStoreDeduplicates() {
+	blobDir, _ := ioutil.TempDir("", "harvester-blobs-test-")
+	defer os.RemoveAll(blobDir)
+	blobs := MakeBlobStore(blobDir)
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{BlobStore: blobs})
+	harvested := ch.HarvestResources(fmt.Sprintf("Files %[1]s/doc.pdf and %[1]s/copy.pdf in a mock tweet", suite.server.URL), suite.span)
+	suite.Require().Equal(2, len(harvested.Resources))
+	first := harvested.Resources[0].ResourceContent().Downloaded()
+	second := harvested.Resources[1].ResourceContent().Downloaded()
+
+	suite.Len(first.Digest, 64)
+	suite.Equal(first.Digest, second.Digest)
+	suite.Equal(first.DestPath, second.DestPath, "Identical downloads should share a single blob")
+	blobPath, err := blobs.Path(first.Digest, "pdf")
+	suite.NoError(err)
+	suite.Equal(blobPath, first.DestPath)
+
+	metadata, err := blobs.Metadata(first.Digest)
+	suite.NoError(err)
+	suite.Equal([]string{suite.server.URL + "/doc.pdf", suite.server.URL + "/copy.pdf"}, metadata.SourceURLs)
+	suite.Equal("application/pdf", metadata.ContentType)
+	suite.Equal(first.Size, metadata.Size)
+
+	suite.NoError(ch.Cleanup())
+	suite.FileExists(first.DestPath, "Blobs should survive closing the harvester")
+}
+
+func TestRetentionSuite(t *testing.T) {
+	suite.Run(t, new(RetentionSuite))
+}
@@ -0,0 +1,502 @@
+package harvester
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/h2non/filetype/types"
+)
+
+// JSONSchemaVersion is the version of the schema written by the JSON and JSON Lines encoders. Decoders
+// accept any document with the same or an older version; fields added later are always optional.
+const JSONSchemaVersion = 1
+
+type harvestedResourcesJSON struct {
+	SchemaVersion int                      `json:"schemaVersion"`
+	Content       string                   `json:"content"`
+	Resources     []*harvestedResourceJSON `json:"resources"`
+}
+
+type harvestedResourceJSON struct {
+	SchemaVersion   int                           `json:"schemaVersion,omitempty"`
+	HarvestedOn     time.Time                     `json:"harvestedOn"`
+	Status          string                        `json:"status,omitempty"`
+	StatusHistory   []resourceStatusChangeJSON    `json:"statusHistory,omitempty"`
+	OriginalURL     string                        `json:"originalURL"`
+	ReferredBy      *harvestedResourceJSON        `json:"referredBy,omitempty"`
+	IsURLValid      bool                          `json:"isURLValid"`
+	IsDestValid     bool                          `json:"isDestValid"`
+	HTTPStatusCode  int                           `json:"httpStatusCode,omitempty"`
+	HTTPStatus      string                        `json:"httpStatus,omitempty"`
+	HTTPHeaders     http.Header                   `json:"httpHeaders,omitempty"`
+	IsGone          bool                          `json:"isGone,omitempty"`
+	IsIgnored       bool                          `json:"isIgnored"`
+	IgnoreReason    string                        `json:"ignoreReason,omitempty"`
+	Error           *errorJSON                    `json:"error,omitempty"`
+	IsCleaned       bool                          `json:"isCleaned"`
+	IsAttachment    bool                          `json:"isAttachment,omitempty"`
+	ResolvedURL     string                        `json:"resolvedURL,omitempty"`
+	CleanedURL      string                        `json:"cleanedURL,omitempty"`
+	FinalURL        string                        `json:"finalURL,omitempty"`
+	ResourceContent *harvestedResourceContentJSON `json:"content,omitempty"`
+	OEmbed          *OEmbed                       `json:"oembed,omitempty"`
+}
+
+type resourceStatusChangeJSON struct {
+	Status string    `json:"status"`
+	On     time.Time `json:"on"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+type harvestedResourceContentJSON struct {
+	URL             string                 `json:"url,omitempty"`
+	ContentType     string                 `json:"contentType,omitempty"`
+	MediaType       string                 `json:"mediaType,omitempty"`
+	MediaTypeParams map[string]string      `json:"mediaTypeParams,omitempty"`
+	MediaTypeError  *errorJSON             `json:"mediaTypeError,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	EncodingError   *errorJSON             `json:"contentEncodingError,omitempty"`
+	TruncatedAt     int64                  `json:"truncatedAtBytes,omitempty"`
+	HTMLParseError  *errorJSON             `json:"htmlParseError,omitempty"`
+	IsHTMLRedirect  bool                   `json:"isHTMLRedirect,omitempty"`
+	HTMLRedirectURL string                 `json:"htmlRedirectURL,omitempty"`
+	Title           string                 `json:"title,omitempty"`
+	MetaTagList     []MetaTag              `json:"metaTagList,omitempty"`
+	Downloaded      *downloadedContentJSON `json:"downloaded,omitempty"`
+	DeadPage        *deadPageJSON          `json:"deadPage,omitempty"`
+	Article         *articleJSON           `json:"article,omitempty"`
+	StructuredData  []*StructuredDataItem  `json:"structuredData,omitempty"`
+	Links           PageLinks              `json:"links,omitempty"`
+	Lang            string                 `json:"lang,omitempty"`
+	Charset         string                 `json:"charset,omitempty"`
+	CharsetSource   CharsetSource          `json:"charsetSource,omitempty"`
+	PDF             *PDFDocument           `json:"pdf,omitempty"`
+	PDFError        *errorJSON             `json:"pdfError,omitempty"`
+}
+
+type articleJSON struct {
+	HTML        string     `json:"html,omitempty"`
+	Text        string     `json:"text,omitempty"`
+	Byline      string     `json:"byline,omitempty"`
+	PublishedOn *time.Time `json:"publishedOn,omitempty"`
+	LeadImage   string     `json:"leadImage,omitempty"`
+}
+
+type deadPageJSON struct {
+	Kind       string   `json:"kind"`
+	Confidence float64  `json:"confidence"`
+	Signals    []string `json:"signals,omitempty"`
+}
+
+type downloadedContentJSON struct {
+	URL           string     `json:"url,omitempty"`
+	DestPath      string     `json:"destPath,omitempty"`
+	DownloadError *errorJSON `json:"downloadError,omitempty"`
+	FileTypeError *errorJSON `json:"fileTypeError,omitempty"`
+	FileExtension string     `json:"fileExtension,omitempty"`
+	FileMIMEType  string     `json:"fileMIMEType,omitempty"`
+	Size          int64      `json:"size,omitempty"`
+	Digest        string     `json:"digest,omitempty"`
+}
+
+// MarshalJSON encodes the harvested resources, including every resource, using the versioned schema
+func (r *HarvestedResources) MarshalJSON() ([]byte, error) {
+	doc := harvestedResourcesJSON{SchemaVersion: JSONSchemaVersion, Content: r.Content}
+	doc.Resources = make([]*harvestedResourceJSON, 0, len(r.Resources))
+	for _, hr := range r.Resources {
+		doc.Resources = append(doc.Resources, hr.toJSON())
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON reconstructs harvested resources encoded by MarshalJSON
+func (r *HarvestedResources) UnmarshalJSON(data []byte) error {
+	var doc harvestedResourcesJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := checkJSONSchemaVersion(doc.SchemaVersion); err != nil {
+		return err
+	}
+
+	r.Content = doc.Content
+	r.Resources = make([]*HarvestedResource, 0, len(doc.Resources))
+	for _, hrJSON := range doc.Resources {
+		hr, err := hrJSON.toResource()
+		if err != nil {
+			return err
+		}
+		r.Resources = append(r.Resources, hr)
+	}
+	return nil
+}
+
+// MarshalJSON encodes a single resource using the versioned schema
+func (r *HarvestedResource) MarshalJSON() ([]byte, error) {
+	doc := r.toJSON()
+	doc.SchemaVersion = JSONSchemaVersion
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON reconstructs a single resource encoded by MarshalJSON
+func (r *HarvestedResource) UnmarshalJSON(data []byte) error {
+	var doc harvestedResourceJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := checkJSONSchemaVersion(doc.SchemaVersion); err != nil {
+		return err
+	}
+
+	hr, err := doc.toResource()
+	if err != nil {
+		return err
+	}
+	*r = *hr
+	return nil
+}
+
+// WriteJSONLines writes each resource as a single JSON document per line (JSON Lines); the
+// content the resources were harvested from is not written
+func (r *HarvestedResources) WriteJSONLines(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, hr := range r.Resources {
+		if err := encoder.Encode(hr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadJSONLines reads resources written by WriteJSONLines, blank lines are skipped
+func ReadJSONLines(reader io.Reader) (*HarvestedResources, error) {
+	result := new(HarvestedResources)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		hr := new(HarvestedResource)
+		if err := hr.UnmarshalJSON(scanner.Bytes()); err != nil {
+			return result, fmt.Errorf("line %d: %v", line, err)
+		}
+		result.Resources = append(result.Resources, hr)
+	}
+	return result, scanner.Err()
+}
+
+func checkJSONSchemaVersion(version int) error {
+	if version > JSONSchemaVersion {
+		return fmt.Errorf("unsupported harvester JSON schema version %d, expected %d or lower", version, JSONSchemaVersion)
+	}
+	return nil
+}
+
+func (r *HarvestedResource) toJSON() *harvestedResourceJSON {
+	result := new(harvestedResourceJSON)
+	result.HarvestedOn = r.harvestedOn
+	result.Status = r.status.String()
+	for _, change := range r.statusHistory {
+		result.StatusHistory = append(result.StatusHistory, resourceStatusChangeJSON{change.Status.String(), change.On, change.Reason})
+	}
+	result.OriginalURL = r.origURLtext
+	if r.origResource != nil {
+		result.ReferredBy = r.origResource.toJSON()
+	}
+	result.IsURLValid = r.isURLValid
+	result.IsDestValid = r.isDestValid
+	result.HTTPStatusCode = r.httpStatusCode
+	result.HTTPStatus = r.httpStatus
+	result.HTTPHeaders = r.httpHeaders
+	result.IsGone = r.isGone
+	result.OEmbed = r.oEmbed
+	result.IsIgnored = r.isURLIgnored
+	result.IgnoreReason = r.ignoreReason
+	result.Error = encodeError(r.err)
+	result.IsCleaned = r.isURLCleaned
+	result.IsAttachment = r.isURLAttachment
+	result.ResolvedURL = urlText(r.resolvedURL)
+	result.CleanedURL = urlText(r.cleanedURL)
+	result.FinalURL = urlText(r.finalURL)
+	if r.resourceContent != nil {
+		result.ResourceContent = r.resourceContent.toJSON()
+	}
+	return result
+}
+
+func (doc *harvestedResourceJSON) toResource() (*HarvestedResource, error) {
+	var err error
+	result := new(HarvestedResource)
+	result.harvestedOn = doc.HarvestedOn
+	result.origURLtext = doc.OriginalURL
+	if doc.ReferredBy != nil {
+		if result.origResource, err = doc.ReferredBy.toResource(); err != nil {
+			return nil, err
+		}
+	}
+	result.isURLValid = doc.IsURLValid
+	result.isDestValid = doc.IsDestValid
+	result.httpStatusCode = doc.HTTPStatusCode
+	result.httpStatus = doc.HTTPStatus
+	result.httpHeaders = doc.HTTPHeaders
+	result.isGone = doc.IsGone
+	result.oEmbed = doc.OEmbed
+	result.isURLIgnored = doc.IsIgnored
+	result.ignoreReason = doc.IgnoreReason
+	result.err = doc.Error.toError()
+	result.isURLCleaned = doc.IsCleaned
+	result.isURLAttachment = doc.IsAttachment
+	if result.resolvedURL, err = parseURLText(doc.ResolvedURL); err != nil {
+		return nil, err
+	}
+	if result.cleanedURL, err = parseURLText(doc.CleanedURL); err != nil {
+		return nil, err
+	}
+	if result.finalURL, err = parseURLText(doc.FinalURL); err != nil {
+		return nil, err
+	}
+	if doc.ResourceContent != nil {
+		if result.resourceContent, err = doc.ResourceContent.toContent(); err != nil {
+			return nil, err
+		}
+	}
+	if err = doc.decodeStatus(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (doc *harvestedResourceJSON) decodeStatus(hr *HarvestedResource) error {
+	var err error
+	if hr.status, err = ParseResourceStatus(doc.Status); err != nil {
+		return err
+	}
+	for _, changeJSON := range doc.StatusHistory {
+		change := ResourceStatusChange{On: changeJSON.On, Reason: changeJSON.Reason}
+		if change.Status, err = ParseResourceStatus(changeJSON.Status); err != nil {
+			return err
+		}
+		hr.statusHistory = append(hr.statusHistory, change)
+	}
+	return nil
+}
+
+func (c *HarvestedResourceContent) toJSON() *harvestedResourceContentJSON {
+	result := new(harvestedResourceContentJSON)
+	result.URL = urlText(c.url)
+	result.ContentType = c.contentType
+	result.MediaType = c.mediaType
+	result.MediaTypeParams = c.mediaTypeParams
+	result.MediaTypeError = encodeError(c.mediaTypeError)
+	result.ContentEncoding = c.contentEncoding
+	result.EncodingError = encodeError(c.contentEncodingError)
+	var tooLarge *TooLargeError
+	if errors.As(c.truncation, &tooLarge) {
+		result.TruncatedAt = tooLarge.Limit
+	}
+	result.HTMLParseError = encodeError(c.htmlParseError)
+	result.IsHTMLRedirect = c.isHTMLRedirect
+	result.HTMLRedirectURL = c.metaRefreshTagContentURLText
+	result.Title = c.titleElementText
+	result.MetaTagList = c.metaTags
+	if c.structuredData != nil {
+		result.StructuredData = c.structuredData.Items
+	}
+	result.Links = c.links
+	result.Lang = c.htmlLang
+	result.Charset = c.charset
+	result.CharsetSource = c.charsetSource
+	result.PDF = c.pdf
+	result.PDFError = encodeError(c.pdfError)
+	if a := c.article; a != nil {
+		result.Article = &articleJSON{HTML: a.HTML, Text: a.Text, Byline: a.Byline, LeadImage: a.LeadImage}
+		if !a.PublishedOn.IsZero() {
+			result.Article.PublishedOn = &a.PublishedOn
+		}
+	}
+	if dp := c.deadPage; dp != nil {
+		result.DeadPage = &deadPageJSON{Kind: dp.Kind.String(), Confidence: dp.Confidence, Signals: dp.Signals}
+	}
+	if dc := c.downloaded; dc != nil {
+		result.Downloaded = &downloadedContentJSON{
+			URL:           urlText(dc.URL),
+			DestPath:      dc.DestPath,
+			DownloadError: encodeError(dc.DownloadError),
+			FileTypeError: encodeError(dc.FileTypeError),
+			FileExtension: dc.FileType.Extension,
+			FileMIMEType:  dc.FileType.MIME.Value,
+			Size:          dc.Size,
+			Digest:        dc.Digest,
+		}
+	}
+	return result
+}
+
+func (doc *harvestedResourceContentJSON) toContent() (*HarvestedResourceContent, error) {
+	var err error
+	result := new(HarvestedResourceContent)
+	if result.url, err = parseURLText(doc.URL); err != nil {
+		return nil, err
+	}
+	result.contentType = doc.ContentType
+	result.mediaType = doc.MediaType
+	result.mediaTypeParams = doc.MediaTypeParams
+	result.mediaTypeError = doc.MediaTypeError.toError()
+	result.contentEncoding = doc.ContentEncoding
+	result.contentEncodingError = doc.EncodingError.toError()
+	if doc.TruncatedAt > 0 {
+		result.truncation = &TooLargeError{URL: doc.URL, Limit: doc.TruncatedAt}
+	}
+	result.htmlParseError = doc.HTMLParseError.toError()
+	result.isHTMLRedirect = doc.IsHTMLRedirect
+	result.metaRefreshTagContentURLText = doc.HTMLRedirectURL
+	result.titleElementText = doc.Title
+	result.metaPropertyTags = make(map[string]string)
+	for _, tag := range doc.MetaTagList {
+		result.addMetaTag(tag.Name, tag.Value)
+	}
+	if len(doc.StructuredData) > 0 {
+		result.structuredData = &StructuredData{Items: doc.StructuredData}
+	}
+	result.links = doc.Links
+	result.htmlLang = doc.Lang
+	result.charset = doc.Charset
+	result.charsetSource = doc.CharsetSource
+	result.pdf = doc.PDF
+	result.pdfError = doc.PDFError.toError()
+	if aJSON := doc.Article; aJSON != nil {
+		result.article = &Article{HTML: aJSON.HTML, Text: aJSON.Text, Byline: aJSON.Byline, LeadImage: aJSON.LeadImage}
+		if aJSON.PublishedOn != nil {
+			result.article.PublishedOn = *aJSON.PublishedOn
+		}
+	}
+	if dpJSON := doc.DeadPage; dpJSON != nil {
+		result.deadPage = &DeadPageDetection{Confidence: dpJSON.Confidence, Signals: dpJSON.Signals}
+		for _, kind := range []DeadPageKind{Soft404Page, ParkedDomainPage} {
+			if kind.String() == dpJSON.Kind {
+				result.deadPage.Kind = kind
+			}
+		}
+	}
+	if dlJSON := doc.Downloaded; dlJSON != nil {
+		dc := new(DownloadedContent)
+		if dc.URL, err = parseURLText(dlJSON.URL); err != nil {
+			return nil, err
+		}
+		dc.DestPath = dlJSON.DestPath
+		dc.DownloadError = dlJSON.DownloadError.toError()
+		dc.FileTypeError = dlJSON.FileTypeError.toError()
+		dc.FileType = types.Type{MIME: types.NewMIME(dlJSON.FileMIMEType), Extension: dlJSON.FileExtension}
+		dc.Size = dlJSON.Size
+		dc.Digest = dlJSON.Digest
+		result.downloaded = dc
+	}
+	return result, nil
+}
+
+func urlText(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+func parseURLText(text string) (*url.URL, error) {
+	if len(text) == 0 {
+		return nil, nil
+	}
+	return url.Parse(text)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// errorJSON records the type and fields of an error so that the typed errors in errors.go survive a round
+// trip; Cause is the error they wrap, if any.
+type errorJSON struct {
+	Kind       string     `json:"kind,omitempty"`
+	Message    string     `json:"message"`
+	URL        string     `json:"url,omitempty"`
+	Host       string     `json:"host,omitempty"`
+	Subject    string     `json:"subject,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	StatusCode int        `json:"statusCode,omitempty"`
+	Gone       bool       `json:"gone,omitempty"`
+	Limit      int64      `json:"limit,omitempty"`
+	Confidence float64    `json:"confidence,omitempty"`
+	Signals    []string   `json:"signals,omitempty"`
+	Cause      *errorJSON `json:"cause,omitempty"`
+}
+
+func encodeError(err error) *errorJSON {
+	if err == nil {
+		return nil
+	}
+	result := &errorJSON{Message: err.Error()}
+	switch e := err.(type) {
+	case *NetworkError:
+		result.Kind, result.URL, result.Cause = "network", e.URL, encodeError(e.Err)
+	case *DNSError:
+		result.Kind, result.URL, result.Host, result.Cause = "dns", e.URL, e.Host, encodeError(e.Err)
+	case *TLSError:
+		result.Kind, result.URL, result.Cause = "tls", e.URL, encodeError(e.Err)
+	case *TimeoutError:
+		result.Kind, result.URL, result.Cause = "timeout", e.URL, encodeError(e.Err)
+	case *HTTPStatusError:
+		result.Kind, result.URL, result.StatusCode, result.Gone = "http-status", e.URL, e.StatusCode, e.Gone
+	case *Soft404Error:
+		result.Kind, result.URL, result.StatusCode, result.Reason = "soft-404", e.URL, e.StatusCode, e.Reason
+	case *ParseError:
+		result.Kind, result.URL, result.Subject, result.Cause = "parse", e.URL, e.Subject, encodeError(e.Err)
+	case *ParkedDomainError:
+		result.Kind, result.URL, result.Confidence, result.Signals = "parked-domain", e.URL, e.Confidence, e.Signals
+	case *IgnoredByRuleError:
+		result.Kind, result.URL, result.Reason = "ignored-by-rule", e.URL, e.Reason
+	case *TooLargeError:
+		result.Kind, result.URL, result.Limit = "too-large", e.URL, e.Limit
+	}
+	return result
+}
+
+// toError rebuilds the typed error that was encoded, or a plain error with the message if the type is unknown
+func (e *errorJSON) toError() error {
+	if e == nil || (len(e.Kind) == 0 && len(e.Message) == 0) {
+		return nil
+	}
+	switch e.Kind {
+	case "network":
+		return &NetworkError{URL: e.URL, Err: e.Cause.toError()}
+	case "dns":
+		return &DNSError{URL: e.URL, Host: e.Host, Err: e.Cause.toError()}
+	case "tls":
+		return &TLSError{URL: e.URL, Err: e.Cause.toError()}
+	case "timeout":
+		return &TimeoutError{URL: e.URL, Err: e.Cause.toError()}
+	case "http-status":
+		return &HTTPStatusError{URL: e.URL, StatusCode: e.StatusCode, Gone: e.Gone}
+	case "soft-404":
+		return &Soft404Error{URL: e.URL, StatusCode: e.StatusCode, Reason: e.Reason}
+	case "parse":
+		return &ParseError{URL: e.URL, Subject: e.Subject, Err: e.Cause.toError()}
+	case "parked-domain":
+		return &ParkedDomainError{URL: e.URL, Confidence: e.Confidence, Signals: e.Signals}
+	case "ignored-by-rule":
+		return &IgnoredByRuleError{URL: e.URL, Reason: e.Reason}
+	case "too-large":
+		return &TooLargeError{URL: e.URL, Limit: e.Limit}
+	}
+	return errors.New(e.Message)
+}
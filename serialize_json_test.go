@@ -0,0 +1,107 @@
+package harvester
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type JSONSuite struct {
+	harvesterSuite
+	harvested *HarvestedResources
+}
+
+func (suite *JSONSuite) SetupSuite() {
+	suite.setupSuite(testFixtures{}.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	suite.harvested = suite.ch.HarvestResources(fmt.Sprintf("Pages %[1]s/og?utm_source=test and %[1]s/missing in a mock tweet", suite.server.URL), suite.span)
+}
+
+func (suite *JSONSuite) TestRoundTrip() {
+	data, err := json.Marshal(suite.harvested)
+	suite.NoError(err)
+	suite.Contains(string(data), fmt.Sprintf(`"schemaVersion":%d`, JSONSchemaVersion))
+
+	decoded := new(HarvestedResources)
+	suite.NoError(json.Unmarshal(data, decoded))
+	suite.Equal(suite.harvested.Content, decoded.Content)
+	suite.Equal(2, len(decoded.Resources))
+
+	hr := decoded.Resources[0]
+	suite.Equal(suite.harvested.Resources[0].OriginalURLText(), hr.OriginalURLText())
+	isCleaned, cleanedURL := hr.IsCleaned()
+	suite.True(isCleaned)
+	suite.Equal(suite.server.URL+"/og", cleanedURL.String())
+	value, ok := hr.ResourceContent().GetOpenGraphMetaTag("title")
+	suite.True(ok)
+	suite.Equal("Open Graph Title", value)
+	suite.True(hr.harvestedOn.Equal(suite.harvested.Resources[0].harvestedOn))
+
+	missing := decoded.Resources[1]
+	_, isDestValid := missing.IsValid()
+	suite.False(isDestValid)
+	suite.Equal(404, missing.httpStatusCode)
+	isIgnored, _ := missing.IsIgnored()
+	suite.False(isIgnored)
+	suite.Equal(ResourceHTTPError, missing.Status())
+	suite.Equal("Invalid HTTP Status Code 404", missing.Err().Error())
+	var statusErr *HTTPStatusError
+	suite.Require().True(errors.As(missing.Err(), &statusErr), "Typed errors should survive the round trip")
+	suite.Equal(404, statusErr.StatusCode)
+	suite.Equal(suite.server.URL+"/missing", statusErr.URL)
+	suite.Equal(len(suite.harvested.Resources[1].StatusHistory()), len(missing.StatusHistory()))
+}
+
+func (suite *JSONSuite) TestTypedErrorsRoundTrip() {
+	cause := errors.New("connection refused")
+	for _, original := range []error{
+		&NetworkError{URL: "https://example.com/", Err: cause},
+		&DNSError{URL: "https://example.com/", Host: "example.com", Err: cause},
+		&TLSError{URL: "https://example.com/", Err: cause},
+		&TimeoutError{URL: "https://example.com/", Err: cause},
+		&HTTPStatusError{URL: "https://example.com/", StatusCode: 410, Gone: true},
+		&Soft404Error{URL: "https://example.com/", StatusCode: 200, Reason: "title matches"},
+		&ParseError{URL: "https://example.com/", Subject: "content encoding", Err: &TooLargeError{URL: "https://example.com/", Limit: 10}},
+		&ParkedDomainError{URL: "https://example.com/", Confidence: 0.9, Signals: []string{"for sale"}},
+		&IgnoredByRuleError{URL: "https://example.com/", Reason: "matched"},
+		&TooLargeError{URL: "https://example.com/", Limit: 1024},
+		cause,
+	} {
+		data, err := json.Marshal(encodeError(original))
+		suite.Require().NoError(err)
+		decoded := new(errorJSON)
+		suite.Require().NoError(json.Unmarshal(data, decoded))
+		suite.Equal(original, decoded.toError(), string(data))
+	}
+
+	var tooLarge *TooLargeError
+	decoded := (&errorJSON{Kind: "parse", Message: "wrapped", Cause: encodeError(&TooLargeError{Limit: 5})}).toError()
+	suite.Require().True(errors.As(decoded, &tooLarge), "Wrapped typed errors should survive the round trip")
+	suite.Equal(int64(5), tooLarge.Limit)
+}
+
+func (suite *JSONSuite) TestJSONLines() {
+	var out strings.Builder
+	suite.NoError(suite.harvested.WriteJSONLines(&out))
+	suite.Equal(2, strings.Count(out.String(), "\n"))
+
+	decoded, err := ReadJSONLines(strings.NewReader(out.String()))
+	suite.NoError(err)
+	suite.Equal(2, len(decoded.Resources))
+	finalURL, _, _ := decoded.Resources[0].GetURLs()
+	suite.Equal(suite.server.URL+"/og", finalURL.String())
+}
+
+func (suite *JSONSuite) TestNewerSchemaRejected() {
+	hr := new(HarvestedResource)
+	err := json.Unmarshal([]byte(`{"schemaVersion": 99, "originalURL": "https://example.com"}`), hr)
+	suite.Error(err)
+}
+
+func TestJSONSuite(t *testing.T) {
+	suite.Run(t, new(JSONSuite))
+}
@@ -0,0 +1,83 @@
+package harvester
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type closeRecorder struct {
+	strings.Builder
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+type SerializeSuite struct {
+	harvesterSuite
+	harvested *HarvestedResources
+}
+
+func (suite *SerializeSuite) SetupSuite() {
+	suite.setupSuite(testFixtures{}.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	suite.harvested = suite.ch.HarvestResources(fmt.Sprintf("Pages %[1]s/og %[1]s/missing %[1]s/title-only %[1]s/untitled in a mock tweet", suite.server.URL), suite.span)
+}
+
+func (suite *SerializeSuite) serializer(writers map[string]*closeRecorder, continueOnError bool) HarvestedResourcesSerializer {
+	tmpl := template.Must(NewTemplate("test").Parse(`{{ .Slug }}`))
+	return HarvestedResourcesSerializer{
+		GetKeys: func(hr *HarvestedResource) *HarvestedResourceKeys {
+			return CreateHarvestedResourceKeys(hr, func(random uint32, try int) bool { return false })
+		},
+		GetTemplate: func(keys *HarvestedResourceKeys) (*template.Template, error) {
+			if keys.Slug() == "only-the-title-element" {
+				return nil, errors.New("no template for this resource")
+			}
+			return tmpl, nil
+		},
+		GetTemplateParams: func(keys *HarvestedResourceKeys) *map[string]interface{} { return nil },
+		OpenWriter: func(keys *HarvestedResourceKeys) (io.Writer, io.Closer, error) {
+			writer := new(closeRecorder)
+			writers[keys.Slug()] = writer
+			return writer, writer, nil
+		},
+		ContinueOnError: continueOnError,
+	}
+}
+
+func (suite *SerializeSuite) TestStopsOnFirstError() {
+	writers := make(map[string]*closeRecorder)
+	report := suite.harvested.SerializeWithReport(suite.serializer(writers, false))
+	suite.Equal(3, len(report.Results), "Serialization should stop at the first failure")
+	suite.EqualError(report.Err(), "no template for this resource")
+	suite.Equal(1, len(writers))
+}
+
+func (suite *SerializeSuite) TestContinuesOnError() {
+	writers := make(map[string]*closeRecorder)
+	report := suite.harvested.SerializeWithReport(suite.serializer(writers, true))
+	suite.Equal(4, len(report.Results))
+	suite.Equal(SerializeWritten, report.Results[0].Outcome)
+	suite.Equal(SerializeSkippedInvalidDest, report.Results[1].Outcome)
+	suite.Equal(SerializeFailed, report.Results[2].Outcome)
+	suite.Equal(SerializeWritten, report.Results[3].Outcome)
+	suite.Equal(2, len(report.WithOutcome(SerializeWritten)))
+	suite.Error(report.Err())
+
+	suite.Equal("open-graph-title", writers["open-graph-title"].String())
+	suite.True(writers["open-graph-title"].closed, "Writers should be closed")
+	suite.True(writers["untitled"].closed, "Writers should be closed")
+}
+
+func TestSerializeSuite(t *testing.T) {
+	suite.Run(t, new(SerializeSuite))
+}
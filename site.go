@@ -0,0 +1,416 @@
+package harvester
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FrontMatterFormat is the format of the front matter written at the top of each Markdown file
+type FrontMatterFormat int
+
+const (
+	// YAMLFrontMatter writes front matter between --- delimiters (Jekyll and Hugo)
+	YAMLFrontMatter FrontMatterFormat = iota
+
+	// TOMLFrontMatter writes front matter between +++ delimiters (Hugo)
+	TOMLFrontMatter
+)
+
+// delimiter returns the line that starts and ends the front matter
+func (f FrontMatterFormat) delimiter() string {
+	if f == TOMLFrontMatter {
+		return "+++"
+	}
+	return "---"
+}
+
+// frontMatterField is a single name/value pair, kept in a slice so fields are written in a stable order
+type frontMatterField struct {
+	name  string
+	value interface{}
+}
+
+// SiteExporter writes one Markdown file with front matter per harvested resource into the content
+// directory of a static site generator such as Hugo or Jekyll
+type SiteExporter struct {
+	contentDir    string
+	format        FrontMatterFormat
+	dateDirLayout string
+	updateInPlace bool
+	keysOptions   HarvestedResourceKeysOptions
+	existsFn      KeyExists
+}
+
+// MakeSiteExporter prepares a site exporter which writes files into contentDir. When updateInPlace is
+// true and a resource's file already exists, only its front matter is replaced and the (possibly hand
+// edited) body is preserved.
+func MakeSiteExporter(contentDir string, format FrontMatterFormat, updateInPlace bool, keysOptions HarvestedResourceKeysOptions, existsFn KeyExists) *SiteExporter {
+	result := new(SiteExporter)
+	result.contentDir = contentDir
+	result.format = format
+	result.dateDirLayout = "2006/01"
+	result.updateInPlace = updateInPlace
+	result.keysOptions = keysOptions
+	result.existsFn = existsFn
+	if result.existsFn == nil {
+		result.existsFn = func(random uint32, try int) bool { return false }
+	}
+	return result
+}
+
+// MakeDefaultSiteExporter prepares a site exporter with YAML front matter, deterministic IDs and
+// update-in-place mode
+func MakeDefaultSiteExporter(contentDir string) *SiteExporter {
+	return MakeSiteExporter(contentDir, YAMLFrontMatter, true, HarvestedResourceKeysOptions{IDStrategy: MakeDefaultContentAddressedResourceIDStrategy()}, nil)
+}
+
+// Export writes a file for each resolved (retrieved and not ignored) resource and returns the paths written
+func (e *SiteExporter) Export(r *HarvestedResources) ([]string, error) {
+	existing, err := e.existingFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, hr := range r.Resources {
+		if status := hr.Status(); status != ResourceResolved && status != ResourceContentError {
+			continue
+		}
+
+		path, err := e.exportResource(hr, r.Content, existing)
+		if err != nil {
+			return written, err
+		}
+		existing[e.ownerKey(hr)] = path
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// ExportResource writes the file for a single resource, using body as the Markdown body, and returns its path.
+// A file written for another resource is never replaced: unless keysOptions has its own SlugStrategy, the slug
+// is made unique by appending a counter, otherwise an error is returned.
+func (e *SiteExporter) ExportResource(hr *HarvestedResource, body string) (string, error) {
+	existing, err := e.existingFiles()
+	if err != nil {
+		return "", err
+	}
+	return e.exportResource(hr, body, existing)
+}
+
+func (e *SiteExporter) exportResource(hr *HarvestedResource, body string, existing map[string]string) (string, error) {
+	options := e.keysOptions
+	if options.SlugStrategy == nil {
+		date := resourceDate(hr)
+		options.SlugStrategy = MakeFallbackSlugStrategy(defaultSlugMaxLength, func(slug string, try int) bool {
+			return e.isTakenByAnother(e.slugPath(date, slug), hr)
+		})
+	}
+	keys := CreateHarvestedResourceKeysWithOptions(hr, e.existsFn, options)
+	path, found := existing[e.ownerKey(hr)]
+	if !found {
+		path = e.slugPath(resourceDate(hr), keys.Slug())
+	}
+	if e.isTakenByAnother(path, hr) {
+		return path, fmt.Errorf("%s was already written for another resource", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return path, err
+	}
+
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		body += "\n"
+	}
+	if e.updateInPlace {
+		existing, err := ioutil.ReadFile(path)
+		if err == nil {
+			body = e.splitBody(existing)
+		} else if !os.IsNotExist(err) {
+			return path, err
+		}
+	}
+
+	var doc bytes.Buffer
+	e.writeFrontMatter(&doc, e.frontMatter(keys))
+	doc.WriteString(body)
+	return path, writeFileAtomically(path, doc.Bytes())
+}
+
+// ResourcePath returns the file the resource's keys will be written to: the file already written for the
+// resource, if there is one, otherwise the content directory, the resource's date formatted as year/month
+// and a file name made of the full date and the slug. Pages which don't declare when they were published
+// are dated when they were harvested, so finding the existing file keeps their path stable.
+func (e *SiteExporter) ResourcePath(keys *HarvestedResourceKeys) string {
+	if existing, err := e.existingFiles(); err == nil {
+		if path, found := existing[e.ownerKey(keys.HarvestedResource())]; found {
+			return path
+		}
+	}
+	return e.slugPath(resourceDate(keys.HarvestedResource()), keys.Slug())
+}
+
+func (e *SiteExporter) slugPath(date time.Time, slug string) string {
+	name := date.Format("2006-01-02") + "-" + slug + ".md"
+	return filepath.Join(e.contentDir, filepath.FromSlash(date.Format(e.dateDirLayout)), name)
+}
+
+// isTakenByAnother returns true if path exists and its front matter doesn't identify hr as the resource
+// it was written for (files which can't be read or have no front matter are never replaced)
+func (e *SiteExporter) isTakenByAnother(path string, hr *HarvestedResource) bool {
+	existing, err := ioutil.ReadFile(path)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	frontMatter, _, found := e.split(existing)
+	if !found {
+		return true
+	}
+	name, value := e.owner(hr)
+	owner, found := e.fieldValue(frontMatter, name)
+	return !found || owner != value
+}
+
+// owner returns the front matter field which identifies the resource a file was written for and its
+// value: the final URL or, if the resource doesn't have one, its original URL
+func (e *SiteExporter) owner(hr *HarvestedResource) (string, string) {
+	if finalURL, _, _ := hr.GetURLs(); finalURL != nil {
+		return "link", urlText(finalURL)
+	}
+	return "originalURL", hr.OriginalURLText()
+}
+
+// ownerKey returns the key of the resource in the map returned by existingFiles
+func (e *SiteExporter) ownerKey(hr *HarvestedResource) string {
+	name, value := e.owner(hr)
+	return name + " " + value
+}
+
+// existingFiles returns the paths of the Markdown files in the content directory by the key (see ownerKey)
+// of the resource they were written for; files without front matter are left out
+func (e *SiteExporter) existingFiles() (map[string]string, error) {
+	result := make(map[string]string)
+	err := filepath.Walk(e.contentDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == e.contentDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		frontMatter, _, found := e.split(data)
+		if !found {
+			return nil
+		}
+		for _, name := range []string{"link", "originalURL"} {
+			if value, found := e.fieldValue(frontMatter, name); found {
+				if _, taken := result[name+" "+value]; !taken {
+					result[name+" "+value] = path
+				}
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// fieldValue returns the unquoted value of the named field in front matter, as written by writeField
+// or edited by hand (double quoted, single quoted or plain)
+func (e *SiteExporter) fieldValue(frontMatter string, name string) (string, bool) {
+	separator := ":"
+	if e.format == TOMLFrontMatter {
+		separator = "="
+	}
+	for _, line := range strings.Split(frontMatter, "\n") {
+		if !strings.HasPrefix(line, name) {
+			continue
+		}
+		rest := strings.TrimSpace(line[len(name):])
+		if !strings.HasPrefix(rest, separator) {
+			continue
+		}
+		value := strings.TrimSpace(rest[len(separator):])
+		switch {
+		case len(value) >= 2 && value[0] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return "", false
+			}
+			return unquoted, true
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.Replace(value[1:len(value)-1], "''", "'", -1), true
+		}
+		return value, true
+	}
+	return "", false
+}
+
+// resourceDate returns the article's published time if the page declares one, otherwise when it was harvested
+func resourceDate(hr *HarvestedResource) time.Time {
+	if published := resourceMetaTag(hr, "article:published_time"); len(published) > 0 {
+		if date, err := time.Parse(time.RFC3339, published); err == nil {
+			return date
+		}
+	}
+	return hr.harvestedOn
+}
+
+func (e *SiteExporter) frontMatter(keys *HarvestedResourceKeys) []frontMatterField {
+	hr := keys.HarvestedResource()
+	finalURL, resolvedURL, _ := hr.GetURLs()
+	isCleaned, _ := hr.IsCleaned()
+
+	fields := []frontMatterField{
+		{"title", keys.Title()},
+		{"description", keys.Description()},
+		{"date", resourceDate(hr)},
+		{"harvestedOn", hr.harvestedOn},
+		{"slug", keys.Slug()},
+		{"id", keys.UniqueKey()},
+		{"link", urlText(finalURL)},
+		{"originalURL", hr.OriginalURLText()},
+		{"resolvedURL", urlText(resolvedURL)},
+		{"urlCleaned", isCleaned},
+		{"source", simplifiedHostname(finalURL)},
+		{"siteName", resourceMetaTag(hr, "og:site_name")},
+		{"type", resourceMetaTag(hr, "og:type")},
+		{"author", resourceMetaTag(hr, "author")},
+		{"twitterSite", resourceMetaTag(hr, "twitter:site")},
+		{"twitterCreator", resourceMetaTag(hr, "twitter:creator")},
+		{"images", keys.Images()},
+	}
+	return fields
+}
+
+func (e *SiteExporter) writeFrontMatter(buf *bytes.Buffer, fields []frontMatterField) {
+	buf.WriteString(e.format.delimiter() + "\n")
+	for _, field := range fields {
+		switch value := field.value.(type) {
+		case string:
+			if len(value) == 0 {
+				continue
+			}
+			e.writeField(buf, field.name, e.quote(value))
+		case bool:
+			e.writeField(buf, field.name, strconv.FormatBool(value))
+		case time.Time:
+			if value.IsZero() {
+				continue
+			}
+			e.writeField(buf, field.name, value.Format(time.RFC3339))
+		case []string:
+			if len(value) == 0 {
+				continue
+			}
+			e.writeList(buf, field.name, value)
+		}
+	}
+	buf.WriteString(e.format.delimiter() + "\n")
+}
+
+func (e *SiteExporter) writeField(buf *bytes.Buffer, name string, value string) {
+	if e.format == TOMLFrontMatter {
+		fmt.Fprintf(buf, "%s = %s\n", name, value)
+		return
+	}
+	fmt.Fprintf(buf, "%s: %s\n", name, value)
+}
+
+func (e *SiteExporter) writeList(buf *bytes.Buffer, name string, values []string) {
+	if e.format == TOMLFrontMatter {
+		fmt.Fprintf(buf, "%s = [", name)
+		for i, value := range values {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(e.quote(value))
+		}
+		buf.WriteString("]\n")
+		return
+	}
+	fmt.Fprintf(buf, "%s:\n", name)
+	for _, value := range values {
+		fmt.Fprintf(buf, "  - %s\n", e.quote(value))
+	}
+}
+
+func (e *SiteExporter) quote(value string) string {
+	if e.format == TOMLFrontMatter {
+		// JSON strings are valid TOML basic strings
+		return QuoteJSON(value)
+	}
+	return QuoteYAML(value)
+}
+
+// splitBody returns everything after the front matter of an existing file (the whole file if it has none)
+func (e *SiteExporter) splitBody(existing []byte) string {
+	_, body, _ := e.split(existing)
+	return body
+}
+
+// split returns the front matter (without its delimiters) and the body of an existing file; found is
+// false, and the body is the whole file, if it doesn't start with front matter. Delimiter lines may end
+// in either LF or CRLF; the front matter is returned with LF line endings but the body is returned
+// exactly as it was written so that hand edits, including their line endings, are preserved.
+func (e *SiteExporter) split(existing []byte) (frontMatter string, body string, found bool) {
+	delimiter := e.format.delimiter()
+	var fm bytes.Buffer
+	for start, lineNum := 0, 0; start < len(existing); lineNum++ {
+		end := bytes.IndexByte(existing[start:], '\n')
+		if end < 0 {
+			break
+		}
+		line := string(bytes.TrimSuffix(existing[start:start+end], []byte("\r")))
+		start += end + 1
+		switch {
+		case lineNum == 0 && line != delimiter:
+			return "", string(existing), false
+		case lineNum == 0:
+		case line == delimiter:
+			return fm.String(), string(existing[start:]), true
+		default:
+			fm.WriteString(line)
+			fm.WriteByte('\n')
+		}
+	}
+	return "", string(existing), false
+}
+
+// writeFileAtomically writes to a temporary file in the destination directory and then renames it so
+// that readers never see a partially written file
+func writeFileAtomically(path string, data []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), ".harvester-tmp-")
+	if err != nil {
+		return err
+	}
+	tmpName := tmpFile.Name()
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err = os.Chmod(tmpName, 0644); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
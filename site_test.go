@@ -0,0 +1,166 @@
+package harvester
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SiteSuite struct {
+	harvesterSuite
+	contentDir string
+}
+
+func (suite *SiteSuite) SetupSuite() {
+	suite.setupSuite(testFixtures{}.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+	suite.contentDir, _ = ioutil.TempDir("", "harvester-site-test-")
+}
+
+func (suite *SiteSuite) TearDownSuite() {
+	os.RemoveAll(suite.contentDir)
+	suite.harvesterSuite.TearDownSuite()
+}
+
+func (suite *SiteSuite) TestYAMLExportUpdatesInPlace() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Pages %[1]s/og and %[1]s/missing in a mock tweet", suite.server.URL), suite.span)
+	exporter := MakeDefaultSiteExporter(filepath.Join(suite.contentDir, "yaml"))
+	written, err := exporter.Export(harvested)
+	suite.NoError(err)
+	suite.Equal(1, len(written), "Invalid resources should not be exported")
+
+	path := written[0]
+	suite.Equal(harvested.Resources[0].harvestedOn.Format("2006-01-02")+"-open-graph-title.md", filepath.Base(path))
+	data, err := ioutil.ReadFile(path)
+	suite.NoError(err)
+	suite.Contains(string(data), "---\ntitle: \"Open Graph Title\"\n")
+	suite.Contains(string(data), "images:\n  - \"https://example.com/og.png\"\n")
+	suite.Contains(string(data), "---\n"+harvested.Content+"\n")
+
+	edited := string(data[:len(data)-len(harvested.Content)-1]) + "Hand edited body\n"
+	suite.NoError(ioutil.WriteFile(path, []byte(edited), 0644))
+	written, err = exporter.Export(harvested)
+	suite.NoError(err)
+	suite.Equal(path, written[0], "Paths should be deterministic")
+	data, _ = ioutil.ReadFile(path)
+	suite.Contains(string(data), "---\nHand edited body\n")
+	suite.NotContains(string(data), harvested.Content)
+}
+
+func (suite *SiteSuite) TestTOMLExport() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Page %s/og in a mock tweet", suite.server.URL), suite.span)
+	exporter := MakeSiteExporter(filepath.Join(suite.contentDir, "toml"), TOMLFrontMatter, false, HarvestedResourceKeysOptions{}, nil)
+	written, err := exporter.Export(harvested)
+	suite.NoError(err)
+	data, _ := ioutil.ReadFile(written[0])
+	suite.Contains(string(data), "+++\ntitle = \"Open Graph Title\"\n")
+	suite.Contains(string(data), "urlCleaned = false\n")
+	suite.Contains(string(data), `images = ["https://example.com/og.png", "https://example.com/twitter.png"]`)
+}
+
+func (suite *SiteSuite) TestCRLFBodyIsPreserved() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Page %s/og in a mock tweet", suite.server.URL), suite.span)
+	exporter := MakeDefaultSiteExporter(filepath.Join(suite.contentDir, "crlf"))
+	written, err := exporter.Export(harvested)
+	suite.Require().NoError(err)
+	data, _ := ioutil.ReadFile(written[0])
+	frontMatter := strings.Replace(string(data[:len(data)-len(harvested.Content)-1]), "\n", "\r\n", -1)
+	body := "Hand edited body\r\n\r\nWith a second paragraph\n"
+	suite.NoError(ioutil.WriteFile(written[0], []byte(frontMatter+body), 0644))
+
+	_, err = exporter.Export(harvested)
+	suite.NoError(err)
+	data, _ = ioutil.ReadFile(written[0])
+	suite.True(strings.HasSuffix(string(data), "\n---\n"+body), "The body should be kept byte-for-byte")
+	suite.Equal(1, strings.Count(string(data), "Hand edited body"))
+}
+
+func (suite *SiteSuite) TestCollidingSlugs() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><head><title>Same Title</title></head><body><p>%s</p></body></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	first := suite.ch.HarvestResources(fmt.Sprintf("First %s/first in a mock tweet", server.URL), suite.span)
+	second := suite.ch.HarvestResources(fmt.Sprintf("Second %s/second in a mock tweet", server.URL), suite.span)
+	second.Resources[0].harvestedOn = first.Resources[0].harvestedOn
+	exporter := MakeDefaultSiteExporter(filepath.Join(suite.contentDir, "colliding"))
+
+	firstPaths, err := exporter.Export(first)
+	suite.Require().NoError(err)
+	secondPaths, err := exporter.Export(second)
+	suite.Require().NoError(err)
+	suite.Equal(first.Resources[0].harvestedOn.Format("2006-01-02")+"-same-title.md", filepath.Base(firstPaths[0]))
+	suite.Equal(first.Resources[0].harvestedOn.Format("2006-01-02")+"-same-title-2.md", filepath.Base(secondPaths[0]))
+	data, _ := ioutil.ReadFile(secondPaths[0])
+	suite.Contains(string(data), "---\n"+second.Content+"\n", "The second resource should not inherit the first one's body")
+
+	// updating in place finds each resource's own file again
+	again, err := exporter.Export(second)
+	suite.NoError(err)
+	suite.Equal(secondPaths, again)
+	again, err = exporter.Export(first)
+	suite.NoError(err)
+	suite.Equal(firstPaths, again)
+	data, _ = ioutil.ReadFile(firstPaths[0])
+	suite.Contains(string(data), "---\n"+first.Content+"\n")
+
+	// a caller supplied slug strategy can't make unique slugs, so the file isn't replaced
+	third := suite.ch.HarvestResources(fmt.Sprintf("Third %s/third in a mock tweet", server.URL), suite.span)
+	third.Resources[0].harvestedOn = first.Resources[0].harvestedOn
+	fixed := MakeSiteExporter(filepath.Join(suite.contentDir, "colliding"), YAMLFrontMatter, true,
+		HarvestedResourceKeysOptions{SlugStrategy: MakeDefaultSlugStrategy()}, nil)
+	_, err = fixed.Export(third)
+	suite.Error(err)
+	data, _ = ioutil.ReadFile(firstPaths[0])
+	suite.Contains(string(data), "---\n"+first.Content+"\n")
+}
+
+func (suite *SiteSuite) TestUndatedPagesAreUpdatedInPlaceLater() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Page %s/og in a mock tweet", suite.server.URL), suite.span)
+	exporter := MakeDefaultSiteExporter(filepath.Join(suite.contentDir, "undated"))
+	written, err := exporter.Export(harvested)
+	suite.Require().NoError(err)
+	data, _ := ioutil.ReadFile(written[0])
+	edited := strings.Replace(string(data), "---\n"+harvested.Content+"\n", "---\nHand edited body\n", 1)
+	suite.NoError(ioutil.WriteFile(written[0], []byte(edited), 0644))
+
+	// the page doesn't declare when it was published, so it's dated when it was harvested
+	harvested.Resources[0].harvestedOn = harvested.Resources[0].harvestedOn.AddDate(0, 2, 3)
+	suite.Equal(written[0], exporter.ResourcePath(CreateHarvestedResourceKeys(harvested.Resources[0], func(random uint32, try int) bool { return false })))
+	again, err := exporter.Export(harvested)
+	suite.NoError(err)
+	suite.Equal(written, again, "Exporting on a later day should update the existing file")
+	data, _ = ioutil.ReadFile(again[0])
+	suite.Contains(string(data), "---\nHand edited body\n")
+}
+
+func (suite *SiteSuite) TestOwnerIsMatchedExactly() {
+	exporter := MakeDefaultSiteExporter(filepath.Join(suite.contentDir, "owners"))
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Page %s/og in a mock tweet", suite.server.URL), suite.span)
+	hr := harvested.Resources[0]
+	link, _, _ := hr.GetURLs()
+	path := filepath.Join(suite.contentDir, "owners", "owned.md")
+	suite.Require().NoError(os.MkdirAll(filepath.Dir(path), 0755))
+
+	for _, owned := range []string{"link: \"" + link.String() + "\"\n", "link: " + link.String() + "\n", "link: '" + link.String() + "'\n"} {
+		suite.NoError(ioutil.WriteFile(path, []byte("---\ntitle: Mine\n"+owned+"---\nBody\n"), 0644))
+		suite.False(exporter.isTakenByAnother(path, hr), owned)
+	}
+	for _, other := range []string{"link: \"" + link.String() + "bc\"\n", "link: \"" + link.String() + "\\n\"\n", "description: \"link: " + link.String() + "\"\n"} {
+		suite.NoError(ioutil.WriteFile(path, []byte("---\ntitle: Theirs\n"+other+"---\nBody\n"), 0644))
+		suite.True(exporter.isTakenByAnother(path, hr), other)
+	}
+}
+
+func TestSiteSuite(t *testing.T) {
+	suite.Run(t, new(SiteSuite))
+}
@@ -0,0 +1,141 @@
+package harvester
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Machiel/slugify"
+	"github.com/rainycape/unidecode"
+)
+
+// SlugExists is a function passed in that checks whether a slug already exists
+type SlugExists func(slug string, try int) bool
+
+// SlugStrategy generates the slug (file name, URL path segment, etc.) for a harvested resource's keys
+type SlugStrategy interface {
+	GenerateSlug(keys *HarvestedResourceKeys) string
+}
+
+// FallbackSlugStrategy generates a slug from the first available of og:title, twitter:title, <title>,
+// the final URL's path and finally the simplified hostname combined with the keys' unique ID
+type FallbackSlugStrategy struct {
+	maxLength int
+	existsFn  SlugExists
+}
+
+// MakeFallbackSlugStrategy prepares a slug strategy which truncates slugs to maxLength characters
+// (on word boundaries, zero means no limit) and, if existsFn is not nil, makes slugs unique by
+// appending a counter
+func MakeFallbackSlugStrategy(maxLength int, existsFn SlugExists) *FallbackSlugStrategy {
+	result := new(FallbackSlugStrategy)
+	result.maxLength = maxLength
+	result.existsFn = existsFn
+	return result
+}
+
+// defaultSlugMaxLength is the maximum length of slugs generated by MakeDefaultSlugStrategy
+const defaultSlugMaxLength = 100
+
+// MakeDefaultSlugStrategy prepares a slug strategy with a maximum length of 100 and no uniqueness check
+func MakeDefaultSlugStrategy() *FallbackSlugStrategy {
+	return MakeFallbackSlugStrategy(defaultSlugMaxLength, nil)
+}
+
+// GenerateSlug returns the first non-empty slug among the fallbacks, made unique if requested
+func (s *FallbackSlugStrategy) GenerateSlug(keys *HarvestedResourceKeys) string {
+	slug := ""
+	for _, candidate := range s.candidates(keys) {
+		slug = s.truncate(SlugifyText(candidate), s.maxLength)
+		if len(slug) > 0 {
+			break
+		}
+	}
+
+	if s.existsFn == nil {
+		return slug
+	}
+
+	unique := slug
+	for try := 0; try < maxResourceIDTries; try++ {
+		if !s.existsFn(unique, try) {
+			return unique
+		}
+		suffix := fmt.Sprintf("-%d", try+2)
+		unique = s.truncate(slug, s.maxLength-len(suffix)) + suffix
+	}
+
+	// give up after max tries, not much we can do
+	return unique
+}
+
+func (s *FallbackSlugStrategy) candidates(keys *HarvestedResourceKeys) []string {
+	var result []string
+	hr := keys.HarvestedResource()
+	if content := hr.ResourceContent(); content != nil {
+		for _, key := range []string{"og:title", "twitter:title"} {
+			if value, ok := content.GetMetaTag(key); ok {
+				result = append(result, value)
+			}
+		}
+		if title, ok := content.GetTitleElement(); ok {
+			result = append(result, title)
+		}
+	}
+	result = append(result, keys.Title())
+
+	finalURL, _, _ := hr.GetURLs()
+	if finalURL != nil {
+		segment := path.Base(finalURL.Path)
+		segment = strings.TrimSuffix(segment, path.Ext(segment))
+		if segment != "/" && segment != "." {
+			result = append(result, segment)
+		}
+		result = append(result, GetSimplifiedHostname(finalURL)+" "+keys.UniqueKey())
+	}
+	result = append(result, "resource "+keys.UniqueKey())
+	return result
+}
+
+// truncate shortens the slug to maxLength characters, preferring to cut between words
+func (s *FallbackSlugStrategy) truncate(slug string, maxLength int) string {
+	if maxLength <= 0 || len(slug) <= maxLength {
+		return slug
+	}
+
+	truncated := slug[:maxLength]
+	if slug[maxLength] != '-' {
+		if lastDash := strings.LastIndex(truncated, "-"); lastDash > 0 {
+			truncated = truncated[:lastDash]
+		}
+	}
+	return strings.Trim(truncated, "-")
+}
+
+// slugReplacements are the characters the slugify library already knows how to replace
+var slugReplacements = slugify.GetDefaultReplacements()
+
+// TransliterateText converts non-Latin characters (e.g. Cyrillic, Greek, CJK) to their closest
+// ASCII representation, leaving characters the slugify library already replaces untouched
+func TransliterateText(text string) string {
+	var result strings.Builder
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			result.WriteRune(r)
+			continue
+		}
+		if _, ok := slugReplacements[unicode.ToLower(r)]; ok {
+			result.WriteRune(r)
+			continue
+		}
+		result.WriteString(unidecode.Unidecode(string(r)))
+	}
+	return result.String()
+}
+
+// SlugifyText transliterates and then slugifies the text
+func SlugifyText(text string) string {
+	return slugify.Slugify(TransliterateText(text))
+}
@@ -0,0 +1,83 @@
+package harvester
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResourceStatus describes how far a discovered resource got through harvesting
+type ResourceStatus int
+
+const (
+	// ResourceDiscovered means the URL was found in content but hasn't been retrieved yet
+	ResourceDiscovered ResourceStatus = iota
+
+	// ResourceResolved means the URL was retrieved and its content inspected successfully
+	ResourceResolved
+
+	// ResourceIgnoredByRule means the URL was retrieved but an IgnoreDiscoveredResourceRule matched it
+	ResourceIgnoredByRule
+
+	// ResourceUnreachable means the URL could not be parsed or retrieved (e.g. DNS, TLS or network failures)
+	ResourceUnreachable
+
+	// ResourceHTTPError means the URL responded with an HTTP status code that isn't accepted
+	ResourceHTTPError
+
+	// ResourceContentError means the URL was retrieved but its content could not be inspected or downloaded
+	ResourceContentError
+)
+
+var resourceStatusNames = map[ResourceStatus]string{
+	ResourceDiscovered:    "discovered",
+	ResourceResolved:      "resolved",
+	ResourceIgnoredByRule: "ignored-by-rule",
+	ResourceUnreachable:   "unreachable",
+	ResourceHTTPError:     "http-error",
+	ResourceContentError:  "content-error",
+}
+
+func (s ResourceStatus) String() string {
+	if name, ok := resourceStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("ResourceStatus(%d)", int(s))
+}
+
+// ParseResourceStatus returns the status with the given name (as returned by String)
+func ParseResourceStatus(name string) (ResourceStatus, error) {
+	for status, statusName := range resourceStatusNames {
+		if statusName == name {
+			return status, nil
+		}
+	}
+	return ResourceDiscovered, fmt.Errorf("unknown resource status %q", name)
+}
+
+// IsFailure returns true if the resource could not be harvested (as opposed to being ignored on purpose)
+func (s ResourceStatus) IsFailure() bool {
+	return s == ResourceUnreachable || s == ResourceHTTPError || s == ResourceContentError
+}
+
+// ResourceStatusChange records a single status transition of a resource
+type ResourceStatusChange struct {
+	Status ResourceStatus
+	On     time.Time
+	Reason string
+}
+
+// Status returns the current status of the resource
+func (r *HarvestedResource) Status() ResourceStatus {
+	return r.status
+}
+
+// StatusHistory returns every status the resource went through, in order, starting with ResourceDiscovered
+func (r *HarvestedResource) StatusHistory() []ResourceStatusChange {
+	return r.statusHistory
+}
+
+// transition moves the resource to the given status and records the change
+func (r *HarvestedResource) transition(status ResourceStatus, reason string) {
+	r.status = status
+	r.statusHistory = append(r.statusHistory, ResourceStatusChange{Status: status, On: time.Now(), Reason: reason})
+}
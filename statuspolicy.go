@@ -0,0 +1,112 @@
+package harvester
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// DefaultSoft404TitlePatterns match the <title> (or og:title) of typical "page not found" pages
+var DefaultSoft404TitlePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^\s*(error\s*)?404\b`),
+	regexp.MustCompile(`(?i)\b(page|file|article|content)\s+not\s+found\b`),
+	regexp.MustCompile(`(?i)^\s*not\s+found\s*$`),
+	regexp.MustCompile(`(?i)\bpage\s+(does\s+not|doesn't|no\s+longer)\s+exists?\b`),
+}
+
+// DefaultSoft404BodyPatterns match the visible text of typical "page not found" pages
+var DefaultSoft404BodyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(page|content|article)\s+you\s+(are|were|'re)\s+looking\s+for\s+(could\s+not\s+be\s+found|cannot\s+be\s+found|can't\s+be\s+found|does\s+not\s+exist|doesn't\s+exist|is\s+no\s+longer\s+available|has\s+been\s+removed)`),
+	regexp.MustCompile(`(?i)\b(the\s+)?requested\s+(page|url|resource)\s+(was\s+not\s+found|could\s+not\s+be\s+found|does\s+not\s+exist)`),
+}
+
+// HTTPStatusPolicy decides which HTTP responses are harvested and which are treated as errors
+type HTTPStatusPolicy struct {
+	// AcceptedStatusCodes are the status codes whose content is harvested; when empty, every 2xx code is accepted
+	AcceptedStatusCodes []int
+
+	// GoneStatusCodes are the (not accepted) status codes which mean the resource is permanently missing,
+	// as opposed to temporarily unavailable (e.g. 429 or 503)
+	GoneStatusCodes []int
+
+	// DetectSoft404 treats accepted HTML pages which look like "page not found" pages as gone; it is off by
+	// default since the patterns can match legitimate pages (e.g. an article titled "Page Not Found")
+	DetectSoft404 bool
+
+	// Soft404TitlePatterns and Soft404BodyPatterns are matched against the page's title and visible
+	// text; when nil, DefaultSoft404TitlePatterns and DefaultSoft404BodyPatterns are used
+	Soft404TitlePatterns []*regexp.Regexp
+	Soft404BodyPatterns  []*regexp.Regexp
+}
+
+// MakeDefaultHTTPStatusPolicy accepts every 2xx response and treats 404 and 410 as gone; soft 404
+// detection is left off, see DetectSoft404
+func MakeDefaultHTTPStatusPolicy() *HTTPStatusPolicy {
+	result := new(HTTPStatusPolicy)
+	result.GoneStatusCodes = []int{http.StatusNotFound, http.StatusGone}
+	return result
+}
+
+// IsAccepted returns true if the content of a response with the given status code should be harvested
+func (p *HTTPStatusPolicy) IsAccepted(statusCode int) bool {
+	if len(p.AcceptedStatusCodes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return containsStatusCode(p.AcceptedStatusCodes, statusCode)
+}
+
+// IsGone returns true if a response with the given status code means the resource is permanently missing
+func (p *HTTPStatusPolicy) IsGone(statusCode int) bool {
+	return containsStatusCode(p.GoneStatusCodes, statusCode)
+}
+
+// IsSoft404 returns true and the reason if the content looks like a "page not found" page
+func (p *HTTPStatusPolicy) IsSoft404(content *HarvestedResourceContent) (bool, string) {
+	if !p.DetectSoft404 || content == nil || !content.IsHTML() {
+		return false, ""
+	}
+
+	titlePatterns, bodyPatterns := p.soft404Patterns()
+	for _, title := range []string{content.titleElementText, content.metaPropertyTags["og:title"]} {
+		if len(title) == 0 {
+			continue
+		}
+		for _, pattern := range titlePatterns {
+			if pattern.MatchString(title) {
+				return true, fmt.Sprintf("Title %q matched soft 404 pattern `%s`", title, pattern.String())
+			}
+		}
+	}
+
+	for _, pattern := range bodyPatterns {
+		if pattern.MatchString(content.bodyTextSample) {
+			return true, fmt.Sprintf("Body matched soft 404 pattern `%s`", pattern.String())
+		}
+	}
+	return false, ""
+}
+
+// soft404Patterns returns the title and body patterns of soft 404 pages, the defaults unless they were set
+// (or p is nil)
+func (p *HTTPStatusPolicy) soft404Patterns() ([]*regexp.Regexp, []*regexp.Regexp) {
+	var titlePatterns, bodyPatterns []*regexp.Regexp
+	if p != nil {
+		titlePatterns, bodyPatterns = p.Soft404TitlePatterns, p.Soft404BodyPatterns
+	}
+	if titlePatterns == nil {
+		titlePatterns = DefaultSoft404TitlePatterns
+	}
+	if bodyPatterns == nil {
+		bodyPatterns = DefaultSoft404BodyPatterns
+	}
+	return titlePatterns, bodyPatterns
+}
+
+func containsStatusCode(codes []int, statusCode int) bool {
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package harvester
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// soft404TestPage is a "page not found" page which is served with 200
+const soft404TestPage = `<html><head><title>Page Not Found | Example</title></head><body><p>Oops</p></body></html>`
+
+// statusPolicyTestFixtures are the pages StatusPolicySuite harvests, served with various status codes
+var statusPolicyTestFixtures = testFixtures{
+	pages: map[string]string{
+		"/non-authoritative": `<html><head><title>Served by a CDN</title></head><body></body></html>`,
+		"/soft-404":          soft404TestPage,
+		"/soft-404-body": `<html><head><title>Example</title></head><body><script>var x = 1;</script>
+		<h1>Oops!</h1><p>The page you are looking for could not be found.</p></body></html>`,
+		"/custom-404": `<html><head><title>Still Useful</title></head><body><p>Content served with a 404</p></body></html>`,
+	},
+	statusCodes: map[string]int{
+		"/non-authoritative": http.StatusNonAuthoritativeInfo,
+		"/custom-404":        http.StatusNotFound,
+		"/gone":              http.StatusGone,
+		"/unavailable":       http.StatusServiceUnavailable,
+	},
+}
+
+type StatusPolicySuite struct {
+	harvesterSuite
+}
+
+func (suite *StatusPolicySuite) SetupSuite() {
+	suite.setupSuite(statusPolicyTestFixtures.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+}
+
+func (suite *StatusPolicySuite) harvest(ch *ContentHarvester, path string) *HarvestedResource {
+	harvested := ch.HarvestResources(fmt.Sprintf("Test page %s%s in a mock tweet", suite.server.URL, path), suite.span)
+	suite.Require().Equal(1, len(harvested.Resources))
+	return harvested.Resources[0]
+}
+
+func (suite *StatusPolicySuite) TestAll2xxAccepted() {
+	hr := suite.harvest(suite.ch, "/non-authoritative")
+	suite.Equal(ResourceResolved, hr.Status())
+	suite.Equal(http.StatusNonAuthoritativeInfo, hr.HTTPStatusCode())
+	suite.Equal("203 Non-Authoritative Information", hr.HTTPStatus())
+	suite.Equal("text/html; charset=utf-8", hr.HTTPHeaders().Get("Content-Type"))
+}
+
+func (suite *StatusPolicySuite) TestGoneStatusCodes() {
+	hr := suite.harvest(suite.ch, "/gone")
+	suite.Equal(ResourceHTTPError, hr.Status())
+	suite.True(hr.IsGone())
+	var statusErr *HTTPStatusError
+	suite.True(errors.As(hr.Err(), &statusErr))
+	suite.True(statusErr.Gone)
+
+	hr = suite.harvest(suite.ch, "/unavailable")
+	suite.Equal(ResourceHTTPError, hr.Status())
+	suite.False(hr.IsGone(), "A temporarily unavailable resource isn't gone")
+}
+
+func (suite *StatusPolicySuite) TestSoft404NotDetectedByDefault() {
+	hr := suite.harvest(suite.ch, "/soft-404")
+	suite.Equal(ResourceResolved, hr.Status(), "A 200 page titled like a 404 page is still harvested by default")
+	suite.False(hr.IsGone())
+	suite.NoError(hr.Err())
+	title, _ := hr.ResourceContent().GetTitleElement()
+	suite.Equal("Page Not Found | Example", title)
+}
+
+func (suite *StatusPolicySuite) TestSoft404() {
+	policy := MakeDefaultHTTPStatusPolicy()
+	policy.DetectSoft404 = true
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{StatusPolicy: policy})
+	defer ch.Close()
+
+	for _, path := range []string{"/soft-404", "/soft-404-body"} {
+		hr := suite.harvest(ch, path)
+		suite.Equal(ResourceHTTPError, hr.Status(), path)
+		suite.True(hr.IsGone(), path)
+		var soft404Err *Soft404Error
+		suite.True(errors.As(hr.Err(), &soft404Err), path)
+		suite.Equal(http.StatusOK, soft404Err.StatusCode)
+	}
+
+	hr := suite.harvest(ch, "/og")
+	suite.Equal(ResourceResolved, hr.Status())
+	suite.False(hr.IsGone())
+}
+
+func (suite *StatusPolicySuite) TestCustomPolicy() {
+	policy := &HTTPStatusPolicy{AcceptedStatusCodes: []int{http.StatusOK, http.StatusNotFound}}
+	ch := MakeContentHarvesterWithOptions(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, ContentHarvesterOptions{StatusPolicy: policy})
+	defer ch.Close()
+
+	hr := suite.harvest(ch, "/custom-404")
+	suite.Equal(ResourceResolved, hr.Status(), "Content served with an accepted 404 should be harvested")
+	title, _ := hr.ResourceContent().GetTitleElement()
+	suite.Equal("Still Useful", title)
+
+	hr = suite.harvest(ch, "/non-authoritative")
+	suite.Equal(ResourceHTTPError, hr.Status(), "Only the accepted status codes should be harvested")
+
+	hr = suite.harvest(ch, "/soft-404")
+	suite.Equal(ResourceResolved, hr.Status(), "Soft 404 detection is off unless requested")
+}
+
+func TestStatusPolicySuite(t *testing.T) {
+	suite.Run(t, new(StatusPolicySuite))
+}
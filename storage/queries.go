@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/lectio/harvester"
+)
+
+// Redirect is a single redirect recorded for a stored resource
+type Redirect struct {
+	Kind    string
+	FromURL string
+	ToURL   string
+}
+
+// FindByCanonicalURL returns the resource stored under the canonical version of u, or nil if there is none
+func (s *SQLiteStore) FindByCanonicalURL(u *url.URL) (*harvester.HarvestedResource, error) {
+	resources, err := s.queryResources(`WHERE canonical_url = ?`, harvester.CanonicalURL(u))
+	if err != nil || len(resources) == 0 {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+// FindByHost returns the resources whose final URL is on the given (simplified, without "www.") host
+func (s *SQLiteStore) FindByHost(host string) ([]*harvester.HarvestedResource, error) {
+	return s.queryResources(`WHERE host = ? ORDER BY harvested_on`, host)
+}
+
+// FindHarvestedBetween returns the resources harvested at or after from and before to
+func (s *SQLiteStore) FindHarvestedBetween(from, to time.Time) ([]*harvester.HarvestedResource, error) {
+	return s.queryResources(`WHERE harvested_on >= ? AND harvested_on < ? ORDER BY harvested_on`, formatTimestamp(from), formatTimestamp(to))
+}
+
+// FindByIgnoreReason returns the ignored resources whose reason matches the SQL LIKE pattern (e.g. "Matched Ignore Rule%")
+func (s *SQLiteStore) FindByIgnoreReason(pattern string) ([]*harvester.HarvestedResource, error) {
+	return s.queryResources(`WHERE is_ignored = 1 AND ignore_reason LIKE ? ORDER BY harvested_on`, pattern)
+}
+
+// FindByStatus returns the resources with the given status (e.g. harvester.ResourceHTTPError)
+func (s *SQLiteStore) FindByStatus(status harvester.ResourceStatus) ([]*harvester.HarvestedResource, error) {
+	return s.queryResources(`WHERE status = ? ORDER BY harvested_on`, status.String())
+}
+
+// FindByHarvest returns the resources which were last stored as part of the given harvest
+func (s *SQLiteStore) FindByHarvest(harvestID int64) ([]*harvester.HarvestedResource, error) {
+	return s.queryResources(`WHERE harvest_id = ? ORDER BY id`, harvestID)
+}
+
+// Redirects returns the redirects recorded for the resource stored under the canonical version of u
+func (s *SQLiteStore) Redirects(u *url.URL) ([]Redirect, error) {
+	rows, err := s.db.Query(`SELECT kind, from_url, to_url FROM redirects
+		JOIN resources ON resources.id = redirects.resource_id WHERE canonical_url = ? ORDER BY redirects.rowid`, harvester.CanonicalURL(u))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Redirect
+	for rows.Next() {
+		var redirect Redirect
+		if err = rows.Scan(&redirect.Kind, &redirect.FromURL, &redirect.ToURL); err != nil {
+			return nil, err
+		}
+		result = append(result, redirect)
+	}
+	return result, rows.Err()
+}
+
+// queryResources reconstructs the resources selected by the where clause from their stored documents
+func (s *SQLiteStore) queryResources(where string, args ...interface{}) ([]*harvester.HarvestedResource, error) {
+	rows, err := s.db.Query(`SELECT document FROM resources `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*harvester.HarvestedResource
+	for rows.Next() {
+		var document string
+		if err = rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		hr := new(harvester.HarvestedResource)
+		if err = json.Unmarshal([]byte(document), hr); err != nil {
+			return nil, err
+		}
+		result = append(result, hr)
+	}
+	return result, rows.Err()
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+func urlText(u *url.URL) sql.NullString {
+	if u == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: u.String(), Valid: true}
+}
+
+func errorText(err error) sql.NullString {
+	if err == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: err.Error(), Valid: true}
+}
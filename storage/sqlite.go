@@ -0,0 +1,303 @@
+// Package storage persists harvested resources into an embedded SQLite database so that they can be
+// queried and re-loaded without harvesting (or parsing serialized Markdown) again.
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lectio/harvester"
+
+	// registers the "sqlite3" database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// timestampLayout is fixed-width and always UTC so that timestamps stored as text sort chronologically
+const timestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// migrations are applied in order, each exactly once; never edit a migration that has been released,
+// add a new one instead
+var migrations = []string{
+	`CREATE TABLE harvests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		content TEXT NOT NULL,
+		stored_on TEXT NOT NULL
+	);
+	CREATE TABLE resources (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		canonical_url TEXT NOT NULL UNIQUE,
+		harvest_id INTEGER REFERENCES harvests(id) ON DELETE SET NULL,
+		original_url TEXT NOT NULL,
+		resolved_url TEXT,
+		cleaned_url TEXT,
+		final_url TEXT,
+		host TEXT,
+		harvested_on TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'discovered',
+		is_url_valid INTEGER NOT NULL,
+		is_dest_valid INTEGER NOT NULL,
+		http_status_code INTEGER,
+		is_ignored INTEGER NOT NULL,
+		ignore_reason TEXT,
+		is_cleaned INTEGER NOT NULL,
+		content_type TEXT,
+		media_type TEXT,
+		document TEXT NOT NULL
+	);
+	CREATE INDEX resources_host ON resources(host);
+	CREATE INDEX resources_harvested_on ON resources(harvested_on);
+	CREATE INDEX resources_ignore_reason ON resources(ignore_reason);
+	CREATE INDEX resources_status ON resources(status);
+	CREATE TABLE redirects (
+		resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		from_url TEXT NOT NULL,
+		to_url TEXT NOT NULL
+	);
+	CREATE INDEX redirects_resource ON redirects(resource_id);
+	CREATE TABLE meta_tags (
+		resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		value TEXT NOT NULL
+	);
+	CREATE INDEX meta_tags_resource ON meta_tags(resource_id);
+	CREATE INDEX meta_tags_name ON meta_tags(name);
+	CREATE TABLE downloads (
+		resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+		url TEXT,
+		dest_path TEXT,
+		file_extension TEXT,
+		mime_type TEXT,
+		download_error TEXT,
+		file_type_error TEXT
+	);
+	CREATE INDEX downloads_resource ON downloads(resource_id);`,
+}
+
+// Redirect kinds stored in the redirects table
+const (
+	// HTTPRedirect is an HTTP (3xx) redirect from the original URL to the resolved URL
+	HTTPRedirect = "http"
+
+	// HTMLRedirect is a <meta http-equiv='refresh'> redirect requested by the content
+	HTMLRedirect = "html"
+
+	// ReferrerRedirect links a resource to the resource whose HTML redirect led to it
+	ReferrerRedirect = "referrer"
+)
+
+// SQLiteStore persists harvested resources in a SQLite database. Resources are keyed by the canonical
+// version of their final URL so storing the same resource again updates it (upsert) instead of
+// creating a duplicate.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens (creating, if necessary) the SQLite database at dataSourceName, for example
+// "harvested.db" or "file::memory:?cache=shared", and applies any pending migrations
+func OpenSQLiteStore(dataSourceName string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only supports a single writer and in-memory databases are per connection
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	result := new(SQLiteStore)
+	result.db = db
+	if err = result.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return result, nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the underlying database for custom queries
+func (s *SQLiteStore) DB() *sql.DB {
+	return s.db
+}
+
+// Migrate applies any migrations which haven't been applied yet and returns the first error encountered
+func (s *SQLiteStore) Migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_on TEXT NOT NULL)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", version, err)
+		}
+		if _, err = tx.Exec(`INSERT INTO schema_migrations (version, applied_on) VALUES (?, ?)`, version, formatTimestamp(time.Now())); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveHarvestedResources stores the content and upserts each of its resources, returning the harvest's ID
+func (s *SQLiteStore) SaveHarvestedResources(r *harvester.HarvestedResources) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	inserted, err := tx.Exec(`INSERT INTO harvests (content, stored_on) VALUES (?, ?)`, r.Content, formatTimestamp(time.Now()))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	harvestID, err := inserted.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, hr := range r.Resources {
+		if err = saveResource(tx, hr, sql.NullInt64{Int64: harvestID, Valid: true}); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return harvestID, tx.Commit()
+}
+
+// SaveResource upserts a single resource which isn't associated with harvested content
+func (s *SQLiteStore) SaveResource(hr *harvester.HarvestedResource) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = saveResource(tx, hr, sql.NullInt64{}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResourceCanonicalURL returns the key a resource is stored under: the canonical version of its final
+// URL or, for resources which were never resolved, the URL as it was discovered
+func ResourceCanonicalURL(hr *harvester.HarvestedResource) string {
+	finalURL, _, _ := hr.GetURLs()
+	if finalURL != nil {
+		return harvester.CanonicalURL(finalURL)
+	}
+	return hr.OriginalURLText()
+}
+
+func saveResource(tx *sql.Tx, hr *harvester.HarvestedResource, harvestID sql.NullInt64) error {
+	document, err := json.Marshal(hr)
+	if err != nil {
+		return err
+	}
+
+	finalURL, resolvedURL, cleanedURL := hr.GetURLs()
+	isURLValid, isDestValid := hr.IsValid()
+	isIgnored, ignoreReason := hr.IsIgnored()
+	isCleaned, _ := hr.IsCleaned()
+	var host, contentType, mediaType string
+	if finalURL != nil {
+		host = harvester.GetSimplifiedHostname(finalURL)
+	}
+	content := hr.ResourceContent()
+	if content != nil {
+		contentType = content.ContentType()
+		mediaType = content.MediaType()
+	}
+
+	canonicalURL := ResourceCanonicalURL(hr)
+	_, err = tx.Exec(`INSERT INTO resources (canonical_url, harvest_id, original_url, resolved_url, cleaned_url, final_url, host,
+			harvested_on, status, is_url_valid, is_dest_valid, http_status_code, is_ignored, ignore_reason, is_cleaned, content_type, media_type, document)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(canonical_url) DO UPDATE SET
+			harvest_id = COALESCE(excluded.harvest_id, harvest_id), original_url = excluded.original_url,
+			resolved_url = excluded.resolved_url, cleaned_url = excluded.cleaned_url, final_url = excluded.final_url,
+			host = excluded.host, harvested_on = excluded.harvested_on, status = excluded.status, is_url_valid = excluded.is_url_valid,
+			is_dest_valid = excluded.is_dest_valid, http_status_code = excluded.http_status_code,
+			is_ignored = excluded.is_ignored, ignore_reason = excluded.ignore_reason, is_cleaned = excluded.is_cleaned,
+			content_type = excluded.content_type, media_type = excluded.media_type, document = excluded.document`,
+		canonicalURL, harvestID, hr.OriginalURLText(), urlText(resolvedURL), urlText(cleanedURL), urlText(finalURL), host,
+		formatTimestamp(hr.HarvestedOn()), hr.Status().String(), isURLValid, isDestValid, hr.HTTPStatusCode(), isIgnored, ignoreReason, isCleaned,
+		contentType, mediaType, string(document))
+	if err != nil {
+		return err
+	}
+
+	var resourceID int64
+	if err = tx.QueryRow(`SELECT id FROM resources WHERE canonical_url = ?`, canonicalURL).Scan(&resourceID); err != nil {
+		return err
+	}
+
+	// the related rows are replaced, not merged, so they always reflect the latest harvest
+	for _, table := range []string{"redirects", "meta_tags", "downloads"} {
+		if _, err = tx.Exec(`DELETE FROM `+table+` WHERE resource_id = ?`, resourceID); err != nil {
+			return err
+		}
+	}
+
+	if resolvedURL != nil && resolvedURL.String() != hr.OriginalURLText() {
+		if err = insertRedirect(tx, resourceID, HTTPRedirect, hr.OriginalURLText(), resolvedURL.String()); err != nil {
+			return err
+		}
+	}
+	if isHTMLRedirect, htmlRedirectURL := hr.IsHTMLRedirect(); isHTMLRedirect {
+		if err = insertRedirect(tx, resourceID, HTMLRedirect, urlText(finalURL).String, htmlRedirectURL); err != nil {
+			return err
+		}
+	}
+	if referrer := hr.ReferredByResource(); referrer != nil {
+		if err = insertRedirect(tx, resourceID, ReferrerRedirect, referrer.OriginalURLText(), hr.OriginalURLText()); err != nil {
+			return err
+		}
+	}
+
+	if content == nil {
+		return nil
+	}
+
+	// every value of repeated names (e.g. og:image) is stored, in document order
+	for _, tag := range content.OrderedMetaTags() {
+		if _, err = tx.Exec(`INSERT INTO meta_tags (resource_id, name, value) VALUES (?, ?, ?)`, resourceID, tag.Name, tag.Value); err != nil {
+			return err
+		}
+	}
+
+	if dc := content.Downloaded(); dc != nil {
+		_, err = tx.Exec(`INSERT INTO downloads (resource_id, url, dest_path, file_extension, mime_type, download_error, file_type_error)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, resourceID, urlText(dc.URL), dc.DestPath, dc.FileType.Extension, dc.FileType.MIME.Value,
+			errorText(dc.DownloadError), errorText(dc.FileTypeError))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertRedirect(tx *sql.Tx, resourceID int64, kind, fromURL, toURL string) error {
+	_, err := tx.Exec(`INSERT INTO redirects (resource_id, kind, from_url, to_url) VALUES (?, ?, ?, ?)`, resourceID, kind, fromURL, toURL)
+	return err
+}
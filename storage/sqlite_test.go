@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lectio/harvester"
+	"github.com/stretchr/testify/suite"
+)
+
+const harvestedJSON = `{
+	"schemaVersion": 1,
+	"content": "Two links https://bit.ly/abc and https://t.co/xyz in a mock tweet",
+	"resources": [
+		{
+			"harvestedOn": "2019-04-01T10:00:00Z",
+			"originalURL": "https://bit.ly/abc",
+			"status": "resolved",
+			"isURLValid": true, "isDestValid": true, "httpStatusCode": 200,
+			"isIgnored": false, "isCleaned": true,
+			"resolvedURL": "https://www.netspective.com/?utm_source=test",
+			"cleanedURL": "https://www.netspective.com/",
+			"finalURL": "https://www.netspective.com/",
+			"content": {
+				"url": "https://www.netspective.com/", "contentType": "text/html; charset=utf-8", "mediaType": "text/html",
+				"title": "Netspective", "metaTagList": [{"name": "og:title", "value": "Netspective"}, {"name": "og:site_name", "value": "Netspective"}]
+			}
+		},
+		{
+			"harvestedOn": "2019-04-02T10:00:00Z",
+			"originalURL": "https://t.co/xyz",
+			"status": "ignored-by-rule",
+			"isURLValid": true, "isDestValid": true, "httpStatusCode": 200,
+			"isIgnored": true, "ignoreReason": "Matched Ignore Rule ` + "`^https://twitter.com/(.*?)/status/(.*)$`" + `",
+			"isCleaned": false,
+			"resolvedURL": "https://twitter.com/lectio/status/1",
+			"finalURL": "https://twitter.com/lectio/status/1"
+		}
+	]
+}`
+
+type SQLiteStoreSuite struct {
+	suite.Suite
+	dir       string
+	store     *SQLiteStore
+	harvested *harvester.HarvestedResources
+}
+
+func (suite *SQLiteStoreSuite) SetupTest() {
+	suite.dir, _ = ioutil.TempDir("", "harvester-storage-test-")
+	store, err := OpenSQLiteStore(filepath.Join(suite.dir, "harvested.db"))
+	suite.Require().NoError(err)
+	suite.store = store
+
+	suite.harvested = new(harvester.HarvestedResources)
+	suite.Require().NoError(json.Unmarshal([]byte(harvestedJSON), suite.harvested))
+}
+
+func (suite *SQLiteStoreSuite) TearDownTest() {
+	suite.store.Close()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *SQLiteStoreSuite) TestSaveAndQuery() {
+	harvestID, err := suite.store.SaveHarvestedResources(suite.harvested)
+	suite.NoError(err)
+
+	byHarvest, err := suite.store.FindByHarvest(harvestID)
+	suite.NoError(err)
+	suite.Equal(2, len(byHarvest))
+
+	byHost, err := suite.store.FindByHost("netspective.com")
+	suite.NoError(err)
+	suite.Equal(1, len(byHost))
+	value, _ := byHost[0].ResourceContent().GetOpenGraphMetaTag("site_name")
+	suite.Equal("Netspective", value)
+
+	ignored, err := suite.store.FindByIgnoreReason("Matched Ignore Rule%")
+	suite.NoError(err)
+	suite.Equal(1, len(ignored))
+	suite.Equal("https://t.co/xyz", ignored[0].OriginalURLText())
+
+	byStatus, err := suite.store.FindByStatus(harvester.ResourceIgnoredByRule)
+	suite.NoError(err)
+	suite.Equal(1, len(byStatus))
+	suite.Equal(harvester.ResourceIgnoredByRule, byStatus[0].Status())
+
+	between, err := suite.store.FindHarvestedBetween(time.Date(2019, 4, 2, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 3, 0, 0, 0, 0, time.UTC))
+	suite.NoError(err)
+	suite.Equal(1, len(between))
+
+	u, _ := url.Parse("https://WWW.netspective.com")
+	found, err := suite.store.FindByCanonicalURL(u)
+	suite.NoError(err)
+	suite.NotNil(found)
+	suite.Equal("https://bit.ly/abc", found.OriginalURLText())
+
+	redirects, err := suite.store.Redirects(u)
+	suite.NoError(err)
+	suite.Equal([]Redirect{{HTTPRedirect, "https://bit.ly/abc", "https://www.netspective.com/?utm_source=test"}}, redirects)
+}
+
+func (suite *SQLiteStoreSuite) TestUpsertByCanonicalURL() {
+	_, err := suite.store.SaveHarvestedResources(suite.harvested)
+	suite.NoError(err)
+	_, err = suite.store.SaveHarvestedResources(suite.harvested)
+	suite.NoError(err)
+
+	var count int
+	suite.NoError(suite.store.DB().QueryRow(`SELECT COUNT(*) FROM resources`).Scan(&count))
+	suite.Equal(2, count, "Saving the same resources again should update, not duplicate, them")
+	suite.NoError(suite.store.DB().QueryRow(`SELECT COUNT(*) FROM meta_tags`).Scan(&count))
+	suite.Equal(2, count)
+}
+
+func (suite *SQLiteStoreSuite) TestTypedErrorsSurvive() {
+	hr := new(harvester.HarvestedResource)
+	suite.Require().NoError(json.Unmarshal([]byte(`{"schemaVersion": 1, "harvestedOn": "2019-04-03T10:00:00Z",
+		"originalURL": "https://example.com/gone", "status": "http-error", "isURLValid": true, "isDestValid": false,
+		"httpStatusCode": 410, "finalURL": "https://example.com/gone",
+		"error": {"kind": "http-status", "message": "Invalid HTTP Status Code 410", "url": "https://example.com/gone", "statusCode": 410, "gone": true}}`), hr))
+	suite.Require().NoError(suite.store.SaveResource(hr))
+
+	found, err := suite.store.FindByStatus(harvester.ResourceHTTPError)
+	suite.Require().NoError(err)
+	suite.Require().Equal(1, len(found))
+	var statusErr *harvester.HTTPStatusError
+	suite.Require().True(errors.As(found[0].Err(), &statusErr))
+	suite.Equal(410, statusErr.StatusCode)
+	suite.True(statusErr.Gone)
+}
+
+func (suite *SQLiteStoreSuite) TestMigrationsAreIdempotent() {
+	suite.NoError(suite.store.Migrate())
+	var version int
+	suite.NoError(suite.store.DB().QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	suite.Equal(len(migrations), version)
+}
+
+func TestSQLiteStoreSuite(t *testing.T) {
+	suite.Run(t, new(SQLiteStoreSuite))
+}
@@ -0,0 +1,493 @@
+package harvester
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// The sources structured data items are found in
+const (
+	JSONLDSource    = "json-ld"
+	MicrodataSource = "microdata"
+	RDFaSource      = "rdfa"
+)
+
+// StructuredDataValue is a single value of a structured data property: either text or a nested item
+type StructuredDataValue struct {
+	Text string              `json:"text,omitempty"`
+	Item *StructuredDataItem `json:"item,omitempty"`
+}
+
+// StructuredDataItem is a single (schema.org) item found in JSON-LD, microdata or RDFa. Types have
+// their schema.org prefix removed (e.g. "NewsArticle") and every property may have multiple values.
+type StructuredDataItem struct {
+	Types      []string                         `json:"types,omitempty"`
+	ID         string                           `json:"id,omitempty"`
+	Source     string                           `json:"source"`
+	Properties map[string][]StructuredDataValue `json:"properties,omitempty"`
+}
+
+// StructuredData is all the top-level structured data items found in a page, in document order
+type StructuredData struct {
+	Items []*StructuredDataItem
+}
+
+// articleTypes are schema.org Article and its commonly used subtypes
+var articleTypes = []string{"Article", "NewsArticle", "BlogPosting", "ReportageNewsArticle", "AnalysisNewsArticle",
+	"OpinionNewsArticle", "TechArticle", "ScholarlyArticle", "Report", "SocialMediaPosting", "LiveBlogPosting"}
+
+// Is returns true if the item has any of the given types
+func (i *StructuredDataItem) Is(types ...string) bool {
+	for _, t := range types {
+		if containsString(i.Types, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns all values of the property
+func (i *StructuredDataItem) Values(property string) []StructuredDataValue {
+	return i.Properties[property]
+}
+
+// Text returns the first value of the property as text; nested items are represented by their name or URL
+func (i *StructuredDataItem) Text(property string) string {
+	texts := i.Texts(property)
+	if len(texts) == 0 {
+		return ""
+	}
+	return texts[0]
+}
+
+// Texts returns every value of the property as text, see Text
+func (i *StructuredDataItem) Texts(property string) []string {
+	var result []string
+	for _, value := range i.Properties[property] {
+		text := value.Text
+		if value.Item != nil {
+			text = value.Item.displayText()
+		}
+		if len(text) > 0 {
+			result = append(result, text)
+		}
+	}
+	return result
+}
+
+// Item returns the first value of the property which is a nested item, or nil
+func (i *StructuredDataItem) Item(property string) *StructuredDataItem {
+	for _, value := range i.Properties[property] {
+		if value.Item != nil {
+			return value.Item
+		}
+	}
+	return nil
+}
+
+func (i *StructuredDataItem) displayText() string {
+	for _, property := range []string{"name", "url", "contentUrl"} {
+		if text := i.Text(property); len(text) > 0 {
+			return text
+		}
+	}
+	return i.ID
+}
+
+func (i *StructuredDataItem) add(property string, value StructuredDataValue) {
+	if i.Properties == nil {
+		i.Properties = make(map[string][]StructuredDataValue)
+	}
+	i.Properties[property] = append(i.Properties[property], value)
+}
+
+// ItemsOfType returns every item, including nested ones, which has any of the given types; items are
+// returned in document order and nested items follow their parent, by property name
+func (d *StructuredData) ItemsOfType(types ...string) []*StructuredDataItem {
+	var result []*StructuredDataItem
+	var f func(items []*StructuredDataItem)
+	f = func(items []*StructuredDataItem) {
+		for _, item := range items {
+			if item.Is(types...) {
+				result = append(result, item)
+			}
+			properties := make([]string, 0, len(item.Properties))
+			for property := range item.Properties {
+				properties = append(properties, property)
+			}
+			sort.Strings(properties)
+			for _, property := range properties {
+				for _, value := range item.Properties[property] {
+					if value.Item != nil {
+						f([]*StructuredDataItem{value.Item})
+					}
+				}
+			}
+		}
+	}
+	if d != nil {
+		f(d.Items)
+	}
+	return result
+}
+
+// StructuredArticle is the article meta data declared by a schema.org Article (or a subtype)
+type StructuredArticle struct {
+	Item          *StructuredDataItem
+	Headline      string
+	Description   string
+	URL           string
+	Authors       []string
+	Publisher     string
+	Images        []string
+	DatePublished time.Time
+	DateModified  time.Time
+}
+
+// StructuredProduct is the product meta data declared by a schema.org Product
+type StructuredProduct struct {
+	Item          *StructuredDataItem
+	Name          string
+	Description   string
+	URL           string
+	Brand         string
+	SKU           string
+	Images        []string
+	Price         string
+	PriceCurrency string
+	Availability  string
+}
+
+// Article returns the first Article, or Article subtype such as NewsArticle or BlogPosting, or nil
+func (d *StructuredData) Article() *StructuredArticle {
+	return d.firstArticle(articleTypes...)
+}
+
+// NewsArticle returns the first NewsArticle, or nil
+func (d *StructuredData) NewsArticle() *StructuredArticle {
+	return d.firstArticle("NewsArticle")
+}
+
+// BlogPosting returns the first BlogPosting, or nil
+func (d *StructuredData) BlogPosting() *StructuredArticle {
+	return d.firstArticle("BlogPosting")
+}
+
+// Product returns the first Product, or nil
+func (d *StructuredData) Product() *StructuredProduct {
+	items := d.ItemsOfType("Product")
+	if len(items) == 0 {
+		return nil
+	}
+	item := items[0]
+	result := &StructuredProduct{Item: item, Name: item.Text("name"), Description: item.Text("description"),
+		URL: item.Text("url"), Brand: item.Text("brand"), SKU: item.Text("sku"), Images: item.Texts("image")}
+	if offer := item.Item("offers"); offer != nil {
+		result.Price = offer.Text("price")
+		if len(result.Price) == 0 {
+			result.Price = offer.Text("lowPrice")
+		}
+		result.PriceCurrency = offer.Text("priceCurrency")
+		result.Availability = schemaOrgName(offer.Text("availability"))
+	}
+	return result
+}
+
+func (d *StructuredData) firstArticle(types ...string) *StructuredArticle {
+	items := d.ItemsOfType(types...)
+	if len(items) == 0 {
+		return nil
+	}
+	item := items[0]
+	result := &StructuredArticle{Item: item, Headline: item.Text("headline"), Description: item.Text("description"),
+		URL: item.Text("url"), Authors: item.Texts("author"), Publisher: item.Text("publisher"), Images: item.Texts("image")}
+	if len(result.Headline) == 0 {
+		result.Headline = item.Text("name")
+	}
+	result.DatePublished, _ = parseArticleDate(item.Text("datePublished"))
+	result.DateModified, _ = parseArticleDate(item.Text("dateModified"))
+	return result
+}
+
+// parseStructuredData finds the JSON-LD, microdata and RDFa items in a parsed page
+func parseStructuredData(doc *html.Node) *StructuredData {
+	result := new(StructuredData)
+	var jsonLDItems []*StructuredDataItem
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "script") &&
+			strings.EqualFold(strings.TrimSpace(htmlAttribute(n, "type")), "application/ld+json") {
+			var script strings.Builder
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					script.WriteString(c.Data)
+				}
+			}
+			jsonLDItems = append(jsonLDItems, parseJSONLD(script.String())...)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	result.Items = append(result.Items, resolveJSONLDReferences(jsonLDItems)...)
+	parseHTMLItems(doc, nil, MicrodataSource, &result.Items)
+	parseHTMLItems(doc, nil, RDFaSource, &result.Items)
+	return result
+}
+
+// parseJSONLD returns the items in a JSON-LD block; invalid blocks are ignored since they're common
+func parseJSONLD(script string) []*StructuredDataItem {
+	var document interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(script)), &document); err != nil {
+		return nil
+	}
+
+	var result []*StructuredDataItem
+	var f func(value interface{})
+	f = func(value interface{}) {
+		switch v := value.(type) {
+		case []interface{}:
+			for _, element := range v {
+				f(element)
+			}
+		case map[string]interface{}:
+			if graph, ok := v["@graph"]; ok {
+				f(graph)
+				return
+			}
+			if item := jsonLDItem(v); item != nil {
+				result = append(result, item)
+			}
+		}
+	}
+	f(document)
+	return result
+}
+
+func jsonLDItem(object map[string]interface{}) *StructuredDataItem {
+	result := &StructuredDataItem{Source: JSONLDSource}
+	for key, value := range object {
+		switch key {
+		case "@type":
+			for _, t := range jsonLDTexts(value) {
+				result.Types = append(result.Types, schemaOrgName(t))
+			}
+		case "@id":
+			result.ID, _ = value.(string)
+		case "@context":
+			// only schema.org vocabulary is expected so the context isn't needed
+		default:
+			if strings.HasPrefix(key, "@") {
+				continue
+			}
+			for _, element := range jsonLDList(value) {
+				if nested, ok := element.(map[string]interface{}); ok {
+					if _, isValue := nested["@value"]; isValue {
+						result.add(key, StructuredDataValue{Text: jsonLDText(nested["@value"])})
+					} else if item := jsonLDItem(nested); item != nil {
+						result.add(key, StructuredDataValue{Item: item})
+					}
+				} else if text := jsonLDText(element); len(text) > 0 {
+					result.add(key, StructuredDataValue{Text: text})
+				}
+			}
+		}
+	}
+	return result
+}
+
+func jsonLDList(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{value}
+}
+
+func jsonLDTexts(value interface{}) []string {
+	var result []string
+	for _, element := range jsonLDList(value) {
+		if text := jsonLDText(element); len(text) > 0 {
+			result = append(result, text)
+		}
+	}
+	return result
+}
+
+func jsonLDText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case nil, map[string]interface{}, []interface{}:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// resolveJSONLDReferences replaces nested items which only reference another item by its @id (common
+// in @graph documents) with a copy of the referenced item. References which would lead back to an
+// item being resolved are left as they are so that the items remain a tree.
+func resolveJSONLDReferences(items []*StructuredDataItem) []*StructuredDataItem {
+	byID := make(map[string]*StructuredDataItem)
+	for _, item := range items {
+		if len(item.ID) > 0 && !item.isReference() {
+			byID[item.ID] = item.clone()
+		}
+	}
+
+	var resolve func(item *StructuredDataItem, resolving []string)
+	resolve = func(item *StructuredDataItem, resolving []string) {
+		if len(item.ID) > 0 {
+			resolving = append(resolving, item.ID)
+		}
+		for _, values := range item.Properties {
+			for i, value := range values {
+				if value.Item == nil {
+					continue
+				}
+				if value.Item.isReference() && !containsString(resolving, value.Item.ID) {
+					if referenced, found := byID[value.Item.ID]; found {
+						values[i].Item = referenced.clone()
+					}
+				}
+				resolve(values[i].Item, resolving)
+			}
+		}
+	}
+	for _, item := range items {
+		resolve(item, nil)
+	}
+	return items
+}
+
+func (i *StructuredDataItem) isReference() bool {
+	return len(i.ID) > 0 && len(i.Types) == 0 && len(i.Properties) == 0
+}
+
+func (i *StructuredDataItem) clone() *StructuredDataItem {
+	result := &StructuredDataItem{Types: i.Types, ID: i.ID, Source: i.Source}
+	for property, values := range i.Properties {
+		for _, value := range values {
+			if value.Item != nil {
+				value.Item = value.Item.clone()
+			}
+			result.add(property, value)
+		}
+	}
+	return result
+}
+
+// parseHTMLItems finds microdata (itemscope, itemtype and itemprop attributes) or RDFa (typeof and
+// property attributes) items; top-level items are appended to items, nested ones are added to item
+func parseHTMLItems(n *html.Node, item *StructuredDataItem, source string, items *[]*StructuredDataItem) {
+	if n.Type == html.ElementNode {
+		scopeAttr, typeAttr, propAttr, idAttrs := "itemscope", "itemtype", "itemprop", []string{"itemid"}
+		if source == RDFaSource {
+			scopeAttr, typeAttr, propAttr, idAttrs = "typeof", "typeof", "property", []string{"resource", "about"}
+		}
+		_, hasScope := htmlAttributeValue(n, scopeAttr)
+		properties := strings.Fields(htmlAttribute(n, propAttr))
+		if item == nil && !hasScope {
+			// properties outside of an item (e.g. RDFa <meta property="og:title">) aren't structured data
+			properties = nil
+		}
+
+		if hasScope {
+			nested := &StructuredDataItem{Source: source}
+			for _, t := range strings.Fields(htmlAttribute(n, typeAttr)) {
+				nested.Types = append(nested.Types, schemaOrgName(t))
+			}
+			for _, attr := range idAttrs {
+				if id := htmlAttribute(n, attr); len(id) > 0 {
+					nested.ID = id
+					break
+				}
+			}
+			if item != nil && len(properties) > 0 {
+				for _, property := range properties {
+					item.add(schemaOrgName(property), StructuredDataValue{Item: nested})
+				}
+			} else {
+				*items = append(*items, nested)
+			}
+			item = nested
+		} else if len(properties) > 0 {
+			value := StructuredDataValue{Text: htmlPropertyValue(n, source)}
+			for _, property := range properties {
+				item.add(schemaOrgName(property), value)
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		parseHTMLItems(c, item, source, items)
+	}
+}
+
+// htmlPropertyValue returns the value of a microdata or RDFa property element
+func htmlPropertyValue(n *html.Node, source string) string {
+	if source == RDFaSource {
+		if content, found := htmlAttributeValue(n, "content"); found {
+			return strings.TrimSpace(content)
+		}
+	}
+	switch strings.ToLower(n.Data) {
+	case "meta":
+		return strings.TrimSpace(htmlAttribute(n, "content"))
+	case "a", "link", "area":
+		return strings.TrimSpace(htmlAttribute(n, "href"))
+	case "img", "audio", "video", "source", "iframe", "embed", "track":
+		return strings.TrimSpace(htmlAttribute(n, "src"))
+	case "object":
+		return strings.TrimSpace(htmlAttribute(n, "data"))
+	case "data", "meter":
+		return strings.TrimSpace(htmlAttribute(n, "value"))
+	case "time":
+		if datetime, found := htmlAttributeValue(n, "datetime"); found {
+			return strings.TrimSpace(datetime)
+		}
+	}
+	return strings.Join(strings.Fields(htmlNodeText(n)), " ")
+}
+
+func htmlNodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var result strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		result.WriteString(htmlNodeText(c))
+		result.WriteByte(' ')
+	}
+	return result.String()
+}
+
+func htmlAttributeValue(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func htmlAttribute(n *html.Node, key string) string {
+	value, _ := htmlAttributeValue(n, key)
+	return value
+}
+
+// schemaOrgName removes the schema.org prefix from a type, property or enumeration value
+func schemaOrgName(name string) string {
+	name = strings.TrimSpace(name)
+	for _, prefix := range []string{"http://schema.org/", "https://schema.org/", "schema:"} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+package harvester
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/html"
+)
+
+const structuredDataPage = `<html><head>
+	<script type="application/ld+json">{
+		"@context": "https://schema.org",
+		"@graph": [
+			{"@type": "Organization", "@id": "https://example.com/#org", "name": "Example News"},
+			{"@type": "Person", "@id": "https://example.com/#jane", "name": "Jane Writer", "worksFor": {"@id": "https://example.com/#org"}},
+			{"@type": ["NewsArticle"], "headline": "Links Harvested", "datePublished": "2019-04-05T08:30:00Z",
+			 "author": [{"@id": "https://example.com/#jane"}, {"@type": "Person", "name": "John Editor"}],
+			 "publisher": {"@id": "https://example.com/#org"},
+			 "image": {"@type": "ImageObject", "url": "https://example.com/lead.png"}}
+		]
+	}</script>
+	<script type="application/ld+json">{ this isn't JSON }</script>
+	<meta property="og:title" content="Not RDFa structured data" />
+	</head><body>
+	<div itemscope itemtype="http://schema.org/Product">
+		<span itemprop="name">Harvester Pro</span>
+		<img itemprop="image" src="https://example.com/pro.png" />
+		<div itemprop="brand" itemscope itemtype="http://schema.org/Brand"><span itemprop="name">Lectio</span></div>
+		<div itemprop="offers" itemscope itemtype="http://schema.org/Offer">
+			<meta itemprop="priceCurrency" content="USD" /><span itemprop="price">19.99</span>
+			<link itemprop="availability" href="http://schema.org/InStock" />
+		</div>
+	</div>
+	<div vocab="http://schema.org/" typeof="BlogPosting">
+		<h2 property="headline">An RDFa Post</h2>
+		<time property="datePublished" datetime="2019-03-01">March 1st</time>
+	</div>
+	</body></html>`
+
+type StructuredDataSuite struct {
+	suite.Suite
+	data *StructuredData
+}
+
+func (suite *StructuredDataSuite) SetupSuite() {
+	doc, err := html.Parse(strings.NewReader(structuredDataPage))
+	suite.Require().NoError(err)
+	suite.data = parseStructuredData(doc)
+}
+
+func (suite *StructuredDataSuite) TestItemSources() {
+	var sources []string
+	for _, item := range suite.data.Items {
+		sources = append(sources, item.Source)
+	}
+	suite.Equal([]string{JSONLDSource, JSONLDSource, JSONLDSource, MicrodataSource, RDFaSource}, sources)
+}
+
+func (suite *StructuredDataSuite) TestJSONLDGraphWithReferences() {
+	article := suite.data.NewsArticle()
+	suite.Require().NotNil(article)
+	suite.Equal("Links Harvested", article.Headline)
+	suite.Equal([]string{"Jane Writer", "John Editor"}, article.Authors)
+	suite.Equal("Example News", article.Publisher)
+	suite.Equal([]string{"https://example.com/lead.png"}, article.Images)
+	suite.True(article.DatePublished.Equal(time.Date(2019, 4, 5, 8, 30, 0, 0, time.UTC)))
+	suite.Equal("Example News", article.Item.Item("author").Item("worksFor").Text("name"), "References should be resolved inside copies too")
+	suite.Equal(article, suite.data.Article(), "Article should include subtypes")
+}
+
+func (suite *StructuredDataSuite) TestMicrodataProduct() {
+	product := suite.data.Product()
+	suite.Require().NotNil(product)
+	suite.Equal("Harvester Pro", product.Name)
+	suite.Equal("Lectio", product.Brand)
+	suite.Equal([]string{"https://example.com/pro.png"}, product.Images)
+	suite.Equal("19.99", product.Price)
+	suite.Equal("USD", product.PriceCurrency)
+	suite.Equal("InStock", product.Availability)
+}
+
+func (suite *StructuredDataSuite) TestRDFaBlogPosting() {
+	posting := suite.data.BlogPosting()
+	suite.Require().NotNil(posting)
+	suite.Equal("An RDFa Post", posting.Headline)
+	suite.True(posting.DatePublished.Equal(time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)))
+	suite.Nil(new(StructuredData).Product())
+}
+
+func (suite *StructuredDataSuite) TestNestedItemsInStableOrder() {
+	doc, err := html.Parse(strings.NewReader(`<html><head><script type="application/ld+json">{
+		"@context": "https://schema.org", "@type": "WebSite", "name": "Example",
+		"hasPart": {"@type": "BlogPosting", "headline": "A Part"},
+		"about": {"@type": "NewsArticle", "headline": "The Subject"},
+		"publisher": {"@type": "Organization", "name": "Example News"}
+	}</script></head><body></body></html>`))
+	suite.Require().NoError(err)
+	data := parseStructuredData(doc)
+	for i := 0; i < 20; i++ {
+		items := data.ItemsOfType(articleTypes...)
+		suite.Require().Equal(2, len(items))
+		suite.Equal("The Subject", items[0].Text("headline"), "Nested items should follow their parent by property name")
+		suite.Equal("A Part", items[1].Text("headline"))
+		suite.Equal("The Subject", data.Article().Headline)
+	}
+}
+
+func TestStructuredDataSuite(t *testing.T) {
+	suite.Run(t, new(StructuredDataSuite))
+}
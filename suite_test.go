@@ -0,0 +1,98 @@
+package harvester
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	"github.com/lectio/observe"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/suite"
+)
+
+// commonTestPages are served to every offline test suite, in addition to the suite's own fixtures
+var commonTestPages = map[string]string{
+	"/og": `<html><head>
+		<title>Element Title</title>
+		<meta property="og:title" content="Open Graph Title" />
+		<meta property="og:description" content="Open Graph Description" />
+		<meta property="og:image" content="https://example.com/og.png" />
+		<meta name="twitter:image" content="https://example.com/twitter.png" />
+		</head><body><p>Hello</p></body></html>`,
+	"/title-only": `<html><head><title> Only the Title Element </title></head><body></body></html>`,
+	"/untitled":   `<html><head></head><body><p>No title here</p></body></html>`,
+}
+
+// testFixtures are what the local HTTP server of an offline test suite serves; paths which aren't
+// listed respond with 404
+type testFixtures struct {
+	pages       map[string]string    // HTML pages by path, see also commonTestPages
+	statusCodes map[string]int       // the status codes paths respond with, 200 unless listed
+	files       map[string][2]string // non-HTML files by path, with their content type
+}
+
+// handler serves the fixtures and commonTestPages
+func (f testFixtures) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if file, found := f.files[r.URL.Path]; found {
+			w.Header().Set("Content-Type", file[0])
+			fmt.Fprint(w, file[1])
+			return
+		}
+		statusCode, found := f.statusCodes[r.URL.Path]
+		if !found {
+			statusCode = http.StatusOK
+		}
+		page, found := f.pages[r.URL.Path]
+		if !found {
+			page, found = commonTestPages[r.URL.Path]
+		}
+		if !found {
+			if statusCode == http.StatusOK {
+				statusCode = http.StatusNotFound
+			}
+			http.Error(w, http.StatusText(statusCode), statusCode)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(statusCode)
+		fmt.Fprint(w, page)
+	})
+}
+
+// harvesterSuite is embedded by the offline test suites; it traces the suite, runs its local HTTP
+// server and closes the suite's harvester, if it has one, once the suite is done
+type harvesterSuite struct {
+	suite.Suite
+	observatory observe.Observatory
+	server      *httptest.Server
+	ch          *ContentHarvester
+	span        opentracing.Span
+}
+
+// setupSuite starts a trace named after the suite and, unless handler is nil, the local HTTP server
+func (s *harvesterSuite) setupSuite(handler http.Handler) {
+	_, set := os.LookupEnv("JAEGER_SERVICE_NAME")
+	if !set {
+		os.Setenv("JAEGER_SERVICE_NAME", "Lectio Harvester Test Suite")
+	}
+
+	s.observatory = observe.MakeObservatoryFromEnv()
+	s.span = s.observatory.StartTrace(strings.TrimPrefix(s.T().Name(), "Test"))
+	if handler != nil {
+		s.server = httptest.NewServer(handler)
+	}
+}
+
+func (s *harvesterSuite) TearDownSuite() {
+	if s.ch != nil {
+		s.ch.Close()
+	}
+	if s.server != nil {
+		s.server.Close()
+	}
+	s.span.Finish()
+	s.observatory.Close()
+}
@@ -0,0 +1,132 @@
+package harvester
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// TemplateFuncs returns the harvester-specific helper functions. Serialize executes the templates
+// returned by HarvestedResourcesSerializer.GetTemplate as they are and doesn't install these, because
+// that would replace functions of the same name defined by the caller; templates which use them must be
+// created with NewTemplate or ParseTemplateFiles (or have TemplateFuncs installed before parsing):
+//
+//	yaml        quotes a value as a double-quoted YAML scalar: {{ .Keys.Title | yaml }}
+//	json        quotes a value as a JSON string: {{ .Keys.Description | json }}
+//	formatDate  formats a time with a Go layout: {{ .HarvestedOn | formatDate "2006-01-02" }}
+//	og          looks up an OpenGraph meta tag: {{ og .Resource "site_name" }}
+//	twitter     looks up a Twitter meta tag: {{ twitter .Resource "creator" }}
+//	meta        looks up any meta tag by property or name: {{ meta .Resource "description" }}
+//	hostname    returns the simplified hostname of a URL or URL text: {{ hostname .FinalURL }}
+//	truncate    shortens text to a maximum number of characters: {{ .Keys.Title | truncate 50 }}
+//	slugify     transliterates and slugifies text: {{ .Keys.Title | slugify }}
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"yaml":       QuoteYAML,
+		"json":       QuoteJSON,
+		"formatDate": formatDate,
+		"og": func(hr *HarvestedResource, key string) string {
+			return resourceMetaTag(hr, "og:"+key)
+		},
+		"twitter": func(hr *HarvestedResource, key string) string {
+			return resourceMetaTag(hr, "twitter:"+key)
+		},
+		"meta":     resourceMetaTag,
+		"hostname": simplifiedHostname,
+		"truncate": truncateText,
+		"slugify":  SlugifyText,
+	}
+}
+
+// NewTemplate creates a new, empty, template with the harvester helper functions installed
+// so that templates parsed from it may reference them
+func NewTemplate(name string) *template.Template {
+	return template.New(name).Funcs(TemplateFuncs())
+}
+
+// ParseTemplateFiles parses the named files into a template with the harvester helper functions
+// installed, the template is named after the first file (like template.ParseFiles)
+func ParseTemplateFiles(filenames ...string) (*template.Template, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("harvester: no files named in call to ParseTemplateFiles")
+	}
+	return NewTemplate(filepath.Base(filenames[0])).ParseFiles(filenames...)
+}
+
+// QuoteYAML returns the value's text as a double-quoted YAML scalar, escaping as necessary
+func QuoteYAML(value interface{}) string {
+	text := fmt.Sprint(value)
+	var result strings.Builder
+	result.WriteByte('"')
+	for _, r := range text {
+		switch r {
+		case '"':
+			result.WriteString(`\"`)
+		case '\\':
+			result.WriteString(`\\`)
+		case '\n':
+			result.WriteString(`\n`)
+		case '\r':
+			result.WriteString(`\r`)
+		case '\t':
+			result.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&result, `\x%02x`, r)
+			} else {
+				result.WriteRune(r)
+			}
+		}
+	}
+	result.WriteByte('"')
+	return result.String()
+}
+
+// QuoteJSON returns the value's text as a JSON string
+func QuoteJSON(value interface{}) string {
+	quoted, _ := json.Marshal(fmt.Sprint(value))
+	return string(quoted)
+}
+
+func formatDate(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+func resourceMetaTag(hr *HarvestedResource, key string) string {
+	if hr == nil || hr.ResourceContent() == nil {
+		return ""
+	}
+	value, _ := hr.ResourceContent().GetMetaTag(key)
+	return value
+}
+
+func simplifiedHostname(value interface{}) string {
+	switch u := value.(type) {
+	case *url.URL:
+		if u == nil {
+			return ""
+		}
+		return GetSimplifiedHostname(u)
+	case url.URL:
+		return GetSimplifiedHostname(&u)
+	default:
+		parsed, err := url.Parse(fmt.Sprint(value))
+		if err != nil {
+			return ""
+		}
+		return GetSimplifiedHostname(parsed)
+	}
+}
+
+func truncateText(length int, text string) string {
+	if length < 0 || utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:length])
+}
@@ -0,0 +1,97 @@
+package harvester
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TemplateSuite struct {
+	harvesterSuite
+}
+
+func (suite *TemplateSuite) SetupSuite() {
+	suite.setupSuite(testFixtures{}.handler())
+	suite.ch = MakeContentHarvester(suite.observatory, defaultIgnoreURLsRegExList, defaultCleanURLsRegExList, false)
+}
+
+func (suite *TemplateSuite) TestQuoting() {
+	suite.Equal(`"He said \"hi\"\nbye \\ o/"`, QuoteYAML("He said \"hi\"\nbye \\ o/"))
+	suite.Equal(`"tab\there"`, QuoteJSON("tab\there"))
+	suite.Equal(`"42"`, QuoteYAML(42))
+}
+
+func (suite *TemplateSuite) TestHelpers() {
+	tmpl := template.Must(NewTemplate("helpers").Parse(`{{ .Title | truncate 5 }}|{{ .Title | slugify }}|{{ hostname .URL }}|{{ .On | formatDate "2006-01-02" }}`))
+	var out strings.Builder
+	err := tmpl.Execute(&out, map[string]interface{}{
+		"Title": "Über Straße",
+		"URL":   "https://www.example.com/path",
+		"On":    time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC),
+	})
+	suite.NoError(err)
+	suite.Equal("Über |ueber-strasse|example.com|2019-04-01", out.String())
+}
+
+// testHelpersTemplate is front matter which uses the helper functions
+const testHelpersTemplate = `---
+title: {{ .Keys.Title | yaml }}
+provSource: {{ .Params.ProvenanceType }}
+harvestedOn: {{ .HarvestedOn | formatDate "2006-01-02T15:04:05Z07:00" }}
+finalURL: {{ .FinalURL | yaml }}
+source: {{ hostname .FinalURL | yaml }}
+slug: {{ .Slug }}
+summary: {{ .Keys.Title | truncate 4 }}
+---
+`
+
+func (suite *TemplateSuite) TestSerializeWithHelpers() {
+	harvested := suite.ch.HarvestResources(fmt.Sprintf("Test page %s/og in a mock tweet", suite.server.URL), suite.span)
+	tmpl, err := NewTemplate("resource").Parse(testHelpersTemplate)
+	suite.Require().NoError(err)
+
+	var out strings.Builder
+	err = harvested.Serialize(HarvestedResourcesSerializer{
+		GetKeys: func(hr *HarvestedResource) *HarvestedResourceKeys {
+			return CreateHarvestedResourceKeys(hr, func(random uint32, try int) bool { return false })
+		},
+		GetTemplate: func(keys *HarvestedResourceKeys) (*template.Template, error) { return tmpl, nil },
+		GetTemplateParams: func(keys *HarvestedResourceKeys) *map[string]interface{} {
+			return &map[string]interface{}{"ProvenanceType": "tweet"}
+		},
+		GetWriter: func(keys *HarvestedResourceKeys) io.Writer { return &out },
+	})
+	suite.NoError(err)
+	suite.Contains(out.String(), `title: "Open Graph Title"`)
+	suite.Contains(out.String(), `source: "127.0.0.1"`)
+	suite.Contains(out.String(), `slug: open-graph-title`)
+	suite.Contains(out.String(), `summary: Open
+`)
+
+	// functions defined by the caller take precedence over the helpers with the same name
+	out.Reset()
+	tmpl, err = NewTemplate("resource").Funcs(template.FuncMap{"truncate": func(int, string) string { return "mine" }}).
+		Parse(testHelpersTemplate)
+	suite.Require().NoError(err)
+	err = harvested.Serialize(HarvestedResourcesSerializer{
+		GetKeys: func(hr *HarvestedResource) *HarvestedResourceKeys {
+			return CreateHarvestedResourceKeys(hr, func(random uint32, try int) bool { return false })
+		},
+		GetTemplate: func(keys *HarvestedResourceKeys) (*template.Template, error) { return tmpl, nil },
+		GetTemplateParams: func(keys *HarvestedResourceKeys) *map[string]interface{} {
+			return &map[string]interface{}{"ProvenanceType": "tweet"}
+		},
+		GetWriter: func(keys *HarvestedResourceKeys) io.Writer { return &out },
+	})
+	suite.NoError(err)
+	suite.Contains(out.String(), `summary: mine`)
+}
+
+func TestTemplateSuite(t *testing.T) {
+	suite.Run(t, new(TemplateSuite))
+}